@@ -55,6 +55,8 @@ func (p *byteParser) sub() tokeniser {
 		tokeniser: p,
 		tState:    len(p.data),
 		start:     p.pos,
+		offset:    p.pos,
+		mark:      p.state(),
 	}
 }
 
@@ -0,0 +1,646 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/token"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"vimagination.zapto.org/parser"
+	"vimagination.zapto.org/parser/peg"
+)
+
+type generator struct {
+	bytes.Buffer
+	grammar  *peg.Grammar
+	compiled *peg.Compiled
+	prefix   string
+	skipped  []string
+	aux      []func()
+	ruleName string
+	auxCount int
+}
+
+// generate produces gofmt'd Go source, in the named package, for the grammar
+// read from the named source file.
+//
+// The prefix is added to every package-level identifier in the generated
+// source; see ident.
+func generate(pkg, prefix, source string, g *peg.Grammar) ([]byte, error) {
+	if prefix != "" && !validPrefix(prefix) {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidPrefix, prefix)
+	}
+
+	c, err := g.Compile()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkPhraseNames(g); err != nil {
+		return nil, err
+	}
+
+	gen := generator{grammar: g, compiled: c, prefix: prefix}
+
+	for _, r := range g.Rules {
+		if r.Skip {
+			gen.skipped = append(gen.skipped, gen.ident(tokenConst(r.Name)))
+		}
+	}
+
+	gen.header(pkg, source)
+	gen.types()
+	gen.tokenise()
+	gen.lexical()
+
+	if gen.hasPhrases() {
+		gen.phrases()
+	}
+
+	gen.helpers()
+
+	return format.Source(gen.Bytes())
+}
+
+func validPrefix(prefix string) bool {
+	r, _ := utf8.DecodeRuneInString(prefix)
+
+	return unicode.IsLetter(r) && token.IsIdentifier(prefix)
+}
+
+// ident returns the package-level identifier for the given name with the
+// prefix added, keeping exported names exported and unexported names
+// unexported; for example, with the prefix calc, Names becomes CalcNames and
+// node becomes calcNode.
+func (g *generator) ident(name string) string {
+	if g.prefix == "" {
+		return name
+	} else if token.IsExported(name) {
+		return upperFirst(g.prefix) + name
+	}
+
+	r, size := utf8.DecodeRuneInString(g.prefix)
+
+	return string(unicode.ToLower(r)) + g.prefix[size:] + upperFirst(name)
+}
+
+func upperFirst(name string) string {
+	r, size := utf8.DecodeRuneInString(name)
+
+	return string(unicode.ToUpper(r)) + name[size:]
+}
+
+// write writes the source, replacing each $name, or ${name}, with the
+// identifier for that name.
+func (g *generator) write(src string) {
+	g.WriteString(os.Expand(src, g.ident))
+}
+
+func (g *generator) hasPhrases() bool {
+	for _, r := range g.grammar.Rules {
+		if isPhrase(r.Name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isPhrase(name string) bool {
+	return name[0] != '_' && (name[0] < 'A' || name[0] > 'Z')
+}
+
+func isFragment(name string) bool {
+	return name[0] == '_'
+}
+
+func tokenConst(name string) string {
+	return "Token" + name
+}
+
+func phraseConst(name string) string {
+	return "Phrase" + strings.ToUpper(name[:1]) + name[1:]
+}
+
+// checkPhraseNames returns an error if two phrase rules or capture labels
+// would generate the same PhraseType constant, such as stmt and Stmt.
+func checkPhraseNames(g *peg.Grammar) error {
+	names := make(map[string]string)
+	check := func(name string, pos peg.Position) error {
+		c := phraseConst(name)
+
+		if other, ok := names[c]; ok && other != name {
+			return &peg.Error{Position: pos, Err: fmt.Errorf("%w: %s and %s are both %s", ErrNameCollision, other, name, c)}
+		}
+
+		names[c] = name
+
+		return nil
+	}
+
+	for _, r := range g.Rules {
+		if isPhrase(r.Name) {
+			if err := check(r.Name, r.Position); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, r := range g.Rules {
+		if err := checkCaptures(r.Expr, check); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func checkCaptures(e peg.Expr, check func(string, peg.Position) error) error {
+	switch e := e.(type) {
+	case *peg.Choice:
+		for _, a := range e.Alternatives {
+			if err := checkCaptures(a, check); err != nil {
+				return err
+			}
+		}
+	case *peg.Sequence:
+		for _, i := range e.Items {
+			if err := checkCaptures(i, check); err != nil {
+				return err
+			}
+		}
+	case *peg.Repeat:
+		return checkCaptures(e.Expr, check)
+	case *peg.Predicate:
+		return checkCaptures(e.Expr, check)
+	case *peg.Capture:
+		if err := check(e.Label, e.Position); err != nil {
+			return err
+		}
+
+		return checkCaptures(e.Expr, check)
+	}
+
+	return nil
+}
+
+func lexFunc(name string) string {
+	return "lex" + name
+}
+
+func ruleVar(name string) string {
+	return "rule" + strings.ToUpper(name[:1]) + name[1:]
+}
+
+func (g *generator) header(pkg, source string) {
+	fmt.Fprintf(g, "// Code generated by parsergen from %s; DO NOT EDIT.\n\n", source)
+	fmt.Fprintf(g, "package %s\n\n", pkg)
+	g.WriteString("import (\n\"errors\"\n\"fmt\"\n")
+
+	if g.hasPhrases() {
+		g.WriteString("\"slices\"\n\"strconv\"\n")
+	}
+
+	g.WriteString("\n\"vimagination.zapto.org/parser\"\n")
+
+	if g.hasPhrases() {
+		g.WriteString("\"vimagination.zapto.org/parser/combinator\"\n")
+	}
+
+	g.WriteString(")\n\n")
+}
+
+func (g *generator) types() {
+	if len(g.compiled.TokenTypes) > 0 {
+		g.WriteString("// Token types.\nconst (\n")
+
+		for n := range len(g.compiled.TokenTypes) {
+			name := g.compiled.Names.Tokens[parser.TokenType(n)]
+
+			if n == 0 {
+				fmt.Fprintf(g, "%s parser.TokenType = iota\n", g.ident(tokenConst(name)))
+			} else {
+				fmt.Fprintf(g, "%s\n", g.ident(tokenConst(name)))
+			}
+		}
+
+		g.WriteString(")\n\n")
+	}
+
+	if len(g.compiled.PhraseTypes) > 0 {
+		g.WriteString("// Phrase types.\nconst (\n")
+
+		for n := range len(g.compiled.PhraseTypes) {
+			name := g.compiled.Names.Phrases[parser.PhraseType(n)]
+
+			if n == 0 {
+				fmt.Fprintf(g, "%s parser.PhraseType = iota\n", g.ident(phraseConst(name)))
+			} else {
+				fmt.Fprintf(g, "%s\n", g.ident(phraseConst(name)))
+			}
+		}
+
+		g.WriteString(")\n\n")
+	}
+
+	g.write("// ${Names} contains the names of the token and phrase types.\nvar ${Names} = &parser.Names{\nTokens: map[parser.TokenType]string{\n")
+
+	for n := range len(g.compiled.TokenTypes) {
+		name := g.compiled.Names.Tokens[parser.TokenType(n)]
+
+		fmt.Fprintf(g, "%s: %q,\n", g.ident(tokenConst(name)), name)
+	}
+
+	g.WriteString("},\nPhrases: map[parser.PhraseType]string{\n")
+
+	for n := range len(g.compiled.PhraseTypes) {
+		name := g.compiled.Names.Phrases[parser.PhraseType(n)]
+
+		fmt.Fprintf(g, "%s: %q,\n", g.ident(phraseConst(name)), name)
+	}
+
+	g.WriteString("},\n}\n\n")
+}
+
+func (g *generator) tokenise() {
+	g.write("// ${Tokenise} is a TokenFunc that tries each of the token rules, in the order\n// they were defined, returning a Token for the first to match.\n")
+	g.write("func ${Tokenise}(t *parser.Tokeniser) (parser.Token, parser.TokenFunc) {\nif t.Peek() == -1 {\nreturn t.Done()\n}\n\n")
+
+	for _, r := range g.grammar.Rules {
+		if isPhrase(r.Name) || isFragment(r.Name) {
+			continue
+		}
+
+		fmt.Fprintf(g, "if %s(t) && t.Len() > 0 {\nreturn t.Return(%s, %s)\n}\n\nt.Reset()\n\n", g.ident(lexFunc(r.Name)), g.ident(tokenConst(r.Name)), g.ident("Tokenise"))
+	}
+
+	g.write("return t.ReturnError(fmt.Errorf(\"%w: %q\", ${ErrNoTokenMatch}, t.Peek()))\n}\n\n")
+}
+
+func (g *generator) lexical() {
+	for _, r := range g.grammar.Rules {
+		if isPhrase(r.Name) {
+			continue
+		}
+
+		g.ruleName = r.Name
+		g.auxCount = 0
+
+		fmt.Fprintf(g, "func %s(t *parser.Tokeniser) bool {\n%s}\n\n", g.ident(lexFunc(r.Name)), g.lexBody(r.Expr))
+
+		for len(g.aux) > 0 {
+			fn := g.aux[0]
+			g.aux = g.aux[1:]
+
+			fn()
+		}
+	}
+}
+
+// auxFunc queues a helper function for the expression, named from the rule
+// being generated; as rule names cannot start with a digit, the names cannot
+// collide with those of the rules.
+func (g *generator) auxFunc(e peg.Expr) string {
+	g.auxCount++
+
+	name := g.ident(fmt.Sprintf("lex%d%s", g.auxCount, g.ruleName))
+
+	g.aux = append(g.aux, func() {
+		fmt.Fprintf(g, "func %s(t *parser.Tokeniser) bool {\n%s}\n\n", name, g.lexBody(e))
+	})
+
+	return name + "(t)"
+}
+
+func (g *generator) lexExpr(e peg.Expr) string {
+	switch e := e.(type) {
+	case *peg.Choice:
+		alts := make([]string, len(e.Alternatives))
+
+		for n, a := range e.Alternatives {
+			alts[n] = g.lexExpr(a)
+		}
+
+		return "(" + strings.Join(alts, " || ") + ")"
+	case *peg.Sequence, *peg.Repeat, *peg.Predicate, *peg.Class:
+		return g.auxFunc(e)
+	case *peg.Reference:
+		return g.ident(lexFunc(e.Name)) + "(t)"
+	case *peg.Literal:
+		return g.ident("acceptLiteral") + "(t, " + strconv.Quote(e.Value) + ")"
+	}
+
+	return g.ident("acceptAny") + "(t)"
+}
+
+func (g *generator) lexBody(e peg.Expr) string {
+	switch e := e.(type) {
+	case *peg.Sequence:
+		items := make([]string, len(e.Items))
+
+		for n, i := range e.Items {
+			items[n] = g.lexExpr(i)
+		}
+
+		return "s := t.State()\n\nif !(" + strings.Join(items, " && ") + ") {\ns.Reset()\n\nreturn false\n}\n\nreturn true\n"
+	case *peg.Repeat:
+		inner := g.lexExpr(e.Expr)
+
+		switch e.Op {
+		case '?':
+			return inner + "\n\nreturn true\n"
+		case '+':
+			return "if !" + inner + " {\nreturn false\n}\n\nfor {\nl := t.Len()\n\nif !" + inner + " || t.Len() == l {\nreturn true\n}\n}\n"
+		}
+
+		return "for {\nl := t.Len()\n\nif !" + inner + " || t.Len() == l {\nreturn true\n}\n}\n"
+	case *peg.Predicate:
+		inner := g.lexExpr(e.Expr)
+		not := ""
+
+		if e.Not {
+			not = "!"
+		}
+
+		return "s := t.State()\nok := " + inner + "\n\ns.Reset()\n\nreturn " + not + "ok\n"
+	case *peg.Class:
+		conds := make([]string, len(e.Ranges))
+
+		for n, r := range e.Ranges {
+			if r.Low == r.High {
+				conds[n] = "r == " + strconv.QuoteRune(r.Low)
+			} else {
+				conds[n] = "r >= " + strconv.QuoteRune(r.Low) + " && r <= " + strconv.QuoteRune(r.High)
+			}
+		}
+
+		if len(conds) == 0 {
+			if e.Negated {
+				return "return " + g.ident("acceptAny") + "(t)\n"
+			}
+
+			return "return false\n"
+		} else if e.Negated {
+			return "switch r := t.Peek(); {\ncase r == -1, " + strings.Join(conds, ", ") + ":\nreturn false\n}\n\nt.Next()\n\nreturn true\n"
+		}
+
+		return "switch r := t.Peek(); {\ncase " + strings.Join(conds, ", ") + ":\nt.Next()\n\nreturn true\n}\n\nreturn false\n"
+	}
+
+	return "return " + g.lexExpr(e) + "\n"
+}
+
+func (g *generator) phrases() {
+	var (
+		rules []string
+		start string
+	)
+
+	for _, r := range g.grammar.Rules {
+		if isPhrase(r.Name) {
+			rules = append(rules, g.ident(ruleVar(r.Name)))
+
+			if start == "" {
+				start = r.Name
+			}
+		}
+	}
+
+	g.WriteString("var (\n")
+	fmt.Fprintf(g, "%s combinator.Rule[[]parser.Child]\n", strings.Join(rules, ", "))
+	g.write("${skip}, ${end} combinator.Rule[[]parser.Child]\n${start} combinator.Rule[*parser.Node]\n)\n\n")
+
+	g.WriteString("func init() {\n")
+
+	fmt.Fprintf(g, "%s = combinator.Silent(combinator.Map(combinator.Many(%s(combinator.Token(%s))), %s))\n", g.ident("skip"), g.ident("tokenChild"), strings.Join(g.skipped, ", "), g.ident("flatten"))
+	g.write("${end} = combinator.Silent(combinator.Map(combinator.Seq(${skip}, combinator.Map(combinator.Token(parser.TokenDone), ${empty})), ${flatten}))\n")
+
+	for _, r := range g.grammar.Rules {
+		if isPhrase(r.Name) {
+			fmt.Fprintf(g, "%s = combinator.Memo(%s(%s, %s))\n", g.ident(ruleVar(r.Name)), g.ident("node"), g.ident(phraseConst(r.Name)), g.phraseExpr(r.Expr))
+		}
+	}
+
+	fmt.Fprintf(g, "%s = %s(%s)\n}\n\n", g.ident("start"), g.ident("startRule"), g.ident(ruleVar(start)))
+
+	g.write(`// ${NewParser} creates a new Parser that reads from the given Tokeniser, using
+// the ${Tokenise} TokenFunc and a PhraseFunc that matches the ` + start + ` rule.
+//
+// Input containing only skipped Tokens results in a PhraseDone Phrase.
+//
+// Each Phrase has its Tree field set to a tree in which each matched phrase
+// rule and capture is a Node.
+func ${NewParser}(t parser.Tokeniser) *parser.Parser {
+	p := parser.New(t)
+
+	p.TokeniserState(${Tokenise})
+
+	in := combinator.NewInput(&p)
+	in.Names = ${Names}
+
+	p.PhraserState(${phraseFunc}(in, ` + g.ident(phraseConst(start)) + `, ${start}))
+
+	return &p
+}
+
+`)
+}
+
+func (g *generator) phraseExpr(e peg.Expr) string {
+	switch e := e.(type) {
+	case *peg.Choice:
+		return "combinator.Choice(\n" + g.phraseExprs(e.Alternatives) + ")"
+	case *peg.Sequence:
+		return "combinator.Map(combinator.Seq(\n" + g.phraseExprs(e.Items) + "), " + g.ident("flatten") + ")"
+	case *peg.Repeat:
+		inner := g.phraseExpr(e.Expr)
+
+		switch e.Op {
+		case '?':
+			return "combinator.Optional(" + inner + ")"
+		case '+':
+			return "combinator.Map(combinator.Many1(" + inner + "), " + g.ident("flatten") + ")"
+		}
+
+		return "combinator.Map(combinator.Many(" + inner + "), " + g.ident("flatten") + ")"
+	case *peg.Predicate:
+		if e.Not {
+			return "combinator.Map(combinator.Not(" + g.phraseExpr(e.Expr) + "), " + g.ident("empty") + ")"
+		}
+
+		return "combinator.Map(combinator.Lookahead(" + g.phraseExpr(e.Expr) + "), " + g.ident("empty") + ")"
+	case *peg.Capture:
+		return g.ident("node") + "(" + g.ident(phraseConst(e.Label)) + ", " + g.phraseExpr(e.Expr) + ")"
+	case *peg.Reference:
+		if isPhrase(e.Name) {
+			return g.ident("ref") + "(&" + g.ident(ruleVar(e.Name)) + ")"
+		}
+
+		return g.ident("terminal") + "(combinator.Token(" + g.ident(tokenConst(e.Name)) + "))"
+	case *peg.Literal:
+		return g.ident("literal") + "(" + strconv.Quote(e.Value) + ")"
+	}
+
+	return g.ident("terminal") + "(combinator.Match(\"any token\", " + g.ident("notSkipped") + "))"
+}
+
+func (g *generator) phraseExprs(es []peg.Expr) string {
+	var sb strings.Builder
+
+	for _, e := range es {
+		sb.WriteString(g.phraseExpr(e))
+		sb.WriteString(",\n")
+	}
+
+	return sb.String()
+}
+
+func (g *generator) helpers() {
+	g.write(`func ${acceptLiteral}(t *parser.Tokeniser, lit string) bool {
+	s := t.State()
+
+	if t.AcceptString(lit, false) == len(lit) {
+		return true
+	}
+
+	s.Reset()
+
+	return false
+}
+
+func ${acceptAny}(t *parser.Tokeniser) bool {
+	if t.Peek() == -1 {
+		return false
+	}
+
+	t.Next()
+
+	return true
+}
+
+`)
+
+	if g.hasPhrases() {
+		g.write(`func ${ref}(r *combinator.Rule[[]parser.Child]) combinator.Rule[[]parser.Child] {
+	return func(in *combinator.Input) ([]parser.Child, bool) {
+		return (*r)(in)
+	}
+}
+
+func ${node}(typ parser.PhraseType, r combinator.Rule[[]parser.Child]) combinator.Rule[[]parser.Child] {
+	return func(in *combinator.Input) ([]parser.Child, bool) {
+		cs, ok := r(in)
+		if !ok {
+			return nil, false
+		}
+
+		n := &parser.Node{Type: typ, Children: cs}
+
+		if len(cs) > 0 {
+			n.Span = parser.Span{Start: cs[0].Span.Start, End: cs[len(cs)-1].Span.End}
+		} else {
+			n.Span = parser.Span{Start: in.End(), End: in.End()}
+		}
+
+		return []parser.Child{{Node: n, Span: n.Span}}, true
+	}
+}
+
+func ${tokenChild}(r combinator.Rule[parser.Token]) combinator.Rule[[]parser.Child] {
+	return func(in *combinator.Input) ([]parser.Child, bool) {
+		start := in.Offset()
+
+		tk, ok := r(in)
+		if !ok {
+			return nil, false
+		}
+
+		return []parser.Child{{Token: tk, Span: parser.Span{Start: start, End: in.End()}}}, true
+	}
+}
+
+func ${flatten}(css [][]parser.Child) []parser.Child {
+	return slices.Concat(css...)
+}
+
+func ${empty}[T any](T) []parser.Child {
+	return nil
+}
+
+func ${isSkipped}(typ parser.TokenType) bool {
+`)
+
+		if len(g.skipped) > 0 {
+			fmt.Fprintf(g, "switch typ {\ncase %s:\nreturn true\n}\n\n", strings.Join(g.skipped, ", "))
+		}
+
+		g.write(`return false
+}
+
+func ${notSkipped}(tk parser.Token) bool {
+	return !${isSkipped}(tk.Type)
+}
+
+func ${terminal}(r combinator.Rule[parser.Token]) combinator.Rule[[]parser.Child] {
+	return combinator.Map(combinator.Seq(${skip}, ${tokenChild}(r)), ${flatten})
+}
+
+func ${literal}(value string) combinator.Rule[[]parser.Child] {
+	return ${terminal}(combinator.Match(strconv.Quote(value), func(tk parser.Token) bool {
+		return tk.Data == value && !${isSkipped}(tk.Type)
+	}))
+}
+
+func ${startRule}(r combinator.Rule[[]parser.Child]) combinator.Rule[*parser.Node] {
+	seq := combinator.Map(combinator.Seq(r, ${skip}), func(cs [][]parser.Child) *parser.Node {
+		root := cs[0][0].Node
+
+		return &parser.Node{
+			Type:     root.Type,
+			Children: append(slices.Clone(root.Children), cs[1]...),
+		}
+	})
+
+	return func(in *combinator.Input) (*parser.Node, bool) {
+		pos := in.Pos()
+
+		n, ok := seq(in)
+		if ok && in.Pos() == pos {
+			return nil, false
+		}
+
+		return n, ok
+	}
+}
+
+func ${phraseFunc}(in *combinator.Input, typ parser.PhraseType, r combinator.Rule[*parser.Node]) parser.PhraseFunc {
+	match := combinator.PhraseFunc(in, typ, r)
+
+	var pf parser.PhraseFunc
+
+	pf = func(p *parser.Parser) (parser.Phrase, parser.PhraseFunc) {
+		if _, ok := ${end}(in); ok {
+			return p.Done()
+		}
+
+		ph, next := match(p)
+		if ph.Type >= 0 {
+			next = pf
+		}
+
+		return ph, next
+	}
+
+	return pf
+}
+
+`)
+	}
+
+	g.write("// Errors.\nvar (\n${ErrNoTokenMatch} = errors.New(\"no token rule matches\")\n)\n")
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"go/format"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"vimagination.zapto.org/parser/peg"
+)
+
+func TestGenerate(t *testing.T) {
+	src, err := os.ReadFile("internal/calc/calc.peg")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected, err := os.ReadFile("internal/calc/calc.go")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	for n := range 2 {
+		g, err := peg.Parse(string(src))
+		if err != nil {
+			t.Fatalf("test %d: unexpected error: %s", n+1, err)
+		}
+
+		code, err := generate("calc", "", "calc.peg", g)
+		if err != nil {
+			t.Fatalf("test %d: unexpected error: %s", n+1, err)
+		} else if !bytes.Equal(code, expected) {
+			t.Errorf("test %d: generated code does not match internal/calc/calc.go; run go generate", n+1)
+		} else if formatted, err := format.Source(code); err != nil {
+			t.Errorf("test %d: unexpected error: %s", n+1, err)
+		} else if !bytes.Equal(formatted, code) {
+			t.Errorf("test %d: generated code is not gofmt'd", n+1)
+		}
+	}
+}
+
+func TestGenerateLexical(t *testing.T) {
+	g, err := peg.Parse("Word <- [^ ]+ / [] / [^]\nSpace <- ' ' &. !'x'")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	code, err := generate("words", "", "words.peg", g)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	} else if bytes.Contains(code, []byte("combinator")) {
+		t.Errorf("expecting no phrase code for grammar without phrase rules")
+	}
+}
+
+func TestGenerateError(t *testing.T) {
+	g, err := peg.Parse("a <- B")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if _, err := generate("a", "", "a.peg", g); !errors.Is(err, peg.ErrUndefinedRule) {
+		t.Errorf("expecting ErrUndefinedRule, got %v", err)
+	}
+}
+
+func TestGenerateCompiles(t *testing.T) {
+	goCmd, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go command not found")
+	}
+
+	for n, src := range [...]string{
+		"Foo <- (\"a\" \"b\")* \"c\"\nFoo1 <- \"c\"",
+		"a <- Foo1 b\nb <- Foo\nFoo <- (\"a\" / [b-c])+ &\"d\"\nFoo1 <- \"e\"? \"d\"",
+	} {
+		g, err := peg.Parse(src)
+		if err != nil {
+			t.Fatalf("test %d: unexpected error: %s", n+1, err)
+		}
+
+		code, err := generate("gen", "", "gen.peg", g)
+		if err != nil {
+			t.Fatalf("test %d: unexpected error: %s", n+1, err)
+		}
+
+		dir, err := os.MkdirTemp(".", "gen")
+		if err != nil {
+			t.Fatalf("test %d: unexpected error: %s", n+1, err)
+		}
+
+		defer os.RemoveAll(dir)
+
+		if err := os.WriteFile(filepath.Join(dir, "gen.go"), code, 0o644); err != nil {
+			t.Fatalf("test %d: unexpected error: %s", n+1, err)
+		}
+
+		if out, err := exec.Command(goCmd, "build", "./"+dir).CombinedOutput(); err != nil {
+			t.Errorf("test %d: generated code does not compile: %s\n%s", n+1, err, out)
+		}
+	}
+}
+
+func TestGenerateNameCollision(t *testing.T) {
+	for n, test := range [...]struct {
+		Input string
+		Pos   peg.Position
+	}{
+		{
+			Input: "stmt <- Ident Stmt:Ident\nIdent <- [a-z]+",
+			Pos:   peg.Position{Line: 1, Column: 15},
+		},
+		{
+			Input: "a <- X:b x:b\nb <- B\nB <- 'b'",
+			Pos:   peg.Position{Line: 1, Column: 10},
+		},
+	} {
+		g, err := peg.Parse(test.Input)
+		if err != nil {
+			t.Fatalf("test %d: unexpected error: %s", n+1, err)
+		}
+
+		var e *peg.Error
+
+		if _, err := generate("a", "", "a.peg", g); !errors.Is(err, ErrNameCollision) {
+			t.Errorf("test %d: expecting ErrNameCollision, got %v", n+1, err)
+		} else if !errors.As(err, &e) {
+			t.Errorf("test %d: expecting *peg.Error, got %T", n+1, err)
+		} else if e.Position != test.Pos {
+			t.Errorf("test %d: expecting error at %s, got %s", n+1, test.Pos, e.Position)
+		}
+	}
+}
+
+func TestGeneratePrefix(t *testing.T) {
+	goCmd, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go command not found")
+	}
+
+	dir, err := os.MkdirTemp(".", "gen")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	for n, test := range [...]struct {
+		Prefix, Input string
+	}{
+		{
+			Prefix: "calc",
+			Input:  "a <- Foo b\nb <- Foo\nFoo <- \"a\" / [b-c]\nSpace <~ ' '",
+		},
+		{
+			Prefix: "List",
+			Input:  "a <- Foo (',' Foo)*\nFoo <- [0-9]+\nSpace <~ ' '",
+		},
+	} {
+		g, err := peg.Parse(test.Input)
+		if err != nil {
+			t.Fatalf("test %d: unexpected error: %s", n+1, err)
+		}
+
+		code, err := generate("gen", test.Prefix, test.Prefix+".peg", g)
+		if err != nil {
+			t.Fatalf("test %d: unexpected error: %s", n+1, err)
+		}
+
+		if err := os.WriteFile(filepath.Join(dir, test.Prefix+".go"), code, 0o644); err != nil {
+			t.Fatalf("test %d: unexpected error: %s", n+1, err)
+		}
+	}
+
+	host := "package gen\n\nvar (\n\tNames, Tokenise, NewParser, ErrNoTokenMatch int\n\tnode, skip, start, end, flatten, empty int\n)\n"
+
+	if err := os.WriteFile(filepath.Join(dir, "host.go"), []byte(host), 0o644); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if out, err := exec.Command(goCmd, "build", "./"+dir).CombinedOutput(); err != nil {
+		t.Errorf("generated code does not compile: %s\n%s", err, out)
+	}
+}
+
+func TestGenerateInvalidPrefix(t *testing.T) {
+	g, err := peg.Parse("a <- A\nA <- 'a'")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	for n, prefix := range [...]string{"1a", "_a", "a-b", "a b"} {
+		if _, err := generate("a", prefix, "a.peg", g); !errors.Is(err, ErrInvalidPrefix) {
+			t.Errorf("test %d: expecting ErrInvalidPrefix, got %v", n+1, err)
+		}
+	}
+}
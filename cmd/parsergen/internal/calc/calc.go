@@ -0,0 +1,474 @@
+// Code generated by parsergen from calc.peg; DO NOT EDIT.
+
+package calc
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+
+	"vimagination.zapto.org/parser"
+	"vimagination.zapto.org/parser/combinator"
+)
+
+// Token types.
+const (
+	TokenWhitespace parser.TokenType = iota
+	TokenComment
+	TokenNumber
+	TokenIdent
+	TokenPunctuator
+)
+
+// Phrase types.
+const (
+	PhraseProgram parser.PhraseType = iota
+	PhraseStatement
+	PhraseExpression
+	PhraseTerm
+	PhraseOp
+)
+
+// Names contains the names of the token and phrase types.
+var Names = &parser.Names{
+	Tokens: map[parser.TokenType]string{
+		TokenWhitespace: "Whitespace",
+		TokenComment:    "Comment",
+		TokenNumber:     "Number",
+		TokenIdent:      "Ident",
+		TokenPunctuator: "Punctuator",
+	},
+	Phrases: map[parser.PhraseType]string{
+		PhraseProgram:    "program",
+		PhraseStatement:  "statement",
+		PhraseExpression: "expression",
+		PhraseTerm:       "term",
+		PhraseOp:         "op",
+	},
+}
+
+// Tokenise is a TokenFunc that tries each of the token rules, in the order
+// they were defined, returning a Token for the first to match.
+func Tokenise(t *parser.Tokeniser) (parser.Token, parser.TokenFunc) {
+	if t.Peek() == -1 {
+		return t.Done()
+	}
+
+	if lexWhitespace(t) && t.Len() > 0 {
+		return t.Return(TokenWhitespace, Tokenise)
+	}
+
+	t.Reset()
+
+	if lexComment(t) && t.Len() > 0 {
+		return t.Return(TokenComment, Tokenise)
+	}
+
+	t.Reset()
+
+	if lexNumber(t) && t.Len() > 0 {
+		return t.Return(TokenNumber, Tokenise)
+	}
+
+	t.Reset()
+
+	if lexIdent(t) && t.Len() > 0 {
+		return t.Return(TokenIdent, Tokenise)
+	}
+
+	t.Reset()
+
+	if lexPunctuator(t) && t.Len() > 0 {
+		return t.Return(TokenPunctuator, Tokenise)
+	}
+
+	t.Reset()
+
+	return t.ReturnError(fmt.Errorf("%w: %q", ErrNoTokenMatch, t.Peek()))
+}
+
+func lexWhitespace(t *parser.Tokeniser) bool {
+	if !lex1Whitespace(t) {
+		return false
+	}
+
+	for {
+		l := t.Len()
+
+		if !lex1Whitespace(t) || t.Len() == l {
+			return true
+		}
+	}
+}
+
+func lex1Whitespace(t *parser.Tokeniser) bool {
+	switch r := t.Peek(); {
+	case r == ' ', r == '\t', r == '\n':
+		t.Next()
+
+		return true
+	}
+
+	return false
+}
+
+func lexComment(t *parser.Tokeniser) bool {
+	s := t.State()
+
+	if !(acceptLiteral(t, "#") && lex1Comment(t)) {
+		s.Reset()
+
+		return false
+	}
+
+	return true
+}
+
+func lex1Comment(t *parser.Tokeniser) bool {
+	for {
+		l := t.Len()
+
+		if !lex2Comment(t) || t.Len() == l {
+			return true
+		}
+	}
+}
+
+func lex2Comment(t *parser.Tokeniser) bool {
+	switch r := t.Peek(); {
+	case r == -1, r == '\n':
+		return false
+	}
+
+	t.Next()
+
+	return true
+}
+
+func lexNumber(t *parser.Tokeniser) bool {
+	s := t.State()
+
+	if !(lex1Number(t) && lex2Number(t)) {
+		s.Reset()
+
+		return false
+	}
+
+	return true
+}
+
+func lex1Number(t *parser.Tokeniser) bool {
+	if !lex_digit(t) {
+		return false
+	}
+
+	for {
+		l := t.Len()
+
+		if !lex_digit(t) || t.Len() == l {
+			return true
+		}
+	}
+}
+
+func lex2Number(t *parser.Tokeniser) bool {
+	lex3Number(t)
+
+	return true
+}
+
+func lex3Number(t *parser.Tokeniser) bool {
+	s := t.State()
+
+	if !(acceptLiteral(t, ".") && lex4Number(t)) {
+		s.Reset()
+
+		return false
+	}
+
+	return true
+}
+
+func lex4Number(t *parser.Tokeniser) bool {
+	if !lex_digit(t) {
+		return false
+	}
+
+	for {
+		l := t.Len()
+
+		if !lex_digit(t) || t.Len() == l {
+			return true
+		}
+	}
+}
+
+func lexIdent(t *parser.Tokeniser) bool {
+	s := t.State()
+
+	if !(lex1Ident(t) && lex2Ident(t)) {
+		s.Reset()
+
+		return false
+	}
+
+	return true
+}
+
+func lex1Ident(t *parser.Tokeniser) bool {
+	switch r := t.Peek(); {
+	case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '_':
+		t.Next()
+
+		return true
+	}
+
+	return false
+}
+
+func lex2Ident(t *parser.Tokeniser) bool {
+	for {
+		l := t.Len()
+
+		if !(lex3Ident(t) || lex_digit(t)) || t.Len() == l {
+			return true
+		}
+	}
+}
+
+func lex3Ident(t *parser.Tokeniser) bool {
+	switch r := t.Peek(); {
+	case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '_':
+		t.Next()
+
+		return true
+	}
+
+	return false
+}
+
+func lexPunctuator(t *parser.Tokeniser) bool {
+	switch r := t.Peek(); {
+	case r == '=', r == ';', r == '+', r == '-', r == '(', r == ')':
+		t.Next()
+
+		return true
+	}
+
+	return false
+}
+
+func lex_digit(t *parser.Tokeniser) bool {
+	switch r := t.Peek(); {
+	case r >= '0' && r <= '9':
+		t.Next()
+
+		return true
+	}
+
+	return false
+}
+
+var (
+	ruleProgram, ruleStatement, ruleExpression, ruleTerm combinator.Rule[[]parser.Child]
+	skip, end                                            combinator.Rule[[]parser.Child]
+	start                                                combinator.Rule[*parser.Node]
+)
+
+func init() {
+	skip = combinator.Silent(combinator.Map(combinator.Many(tokenChild(combinator.Token(TokenWhitespace, TokenComment))), flatten))
+	end = combinator.Silent(combinator.Map(combinator.Seq(skip, combinator.Map(combinator.Token(parser.TokenDone), empty)), flatten))
+	ruleProgram = combinator.Memo(node(PhraseProgram, combinator.Map(combinator.Many(ref(&ruleStatement)), flatten)))
+	ruleStatement = combinator.Memo(node(PhraseStatement, combinator.Choice(
+		combinator.Map(combinator.Seq(
+			terminal(combinator.Token(TokenIdent)),
+			literal("="),
+			ref(&ruleExpression),
+			literal(";"),
+		), flatten),
+		combinator.Map(combinator.Seq(
+			ref(&ruleExpression),
+			literal(";"),
+		), flatten),
+	)))
+	ruleExpression = combinator.Memo(node(PhraseExpression, combinator.Map(combinator.Seq(
+		ref(&ruleTerm),
+		combinator.Map(combinator.Many(combinator.Map(combinator.Seq(
+			node(PhraseOp, combinator.Choice(
+				literal("+"),
+				literal("-"),
+			)),
+			ref(&ruleTerm),
+		), flatten)), flatten),
+	), flatten)))
+	ruleTerm = combinator.Memo(node(PhraseTerm, combinator.Choice(
+		terminal(combinator.Token(TokenNumber)),
+		terminal(combinator.Token(TokenIdent)),
+		combinator.Map(combinator.Seq(
+			literal("("),
+			ref(&ruleExpression),
+			literal(")"),
+		), flatten),
+	)))
+	start = startRule(ruleProgram)
+}
+
+// NewParser creates a new Parser that reads from the given Tokeniser, using
+// the Tokenise TokenFunc and a PhraseFunc that matches the program rule.
+//
+// Input containing only skipped Tokens results in a PhraseDone Phrase.
+//
+// Each Phrase has its Tree field set to a tree in which each matched phrase
+// rule and capture is a Node.
+func NewParser(t parser.Tokeniser) *parser.Parser {
+	p := parser.New(t)
+
+	p.TokeniserState(Tokenise)
+
+	in := combinator.NewInput(&p)
+	in.Names = Names
+
+	p.PhraserState(phraseFunc(in, PhraseProgram, start))
+
+	return &p
+}
+
+func acceptLiteral(t *parser.Tokeniser, lit string) bool {
+	s := t.State()
+
+	if t.AcceptString(lit, false) == len(lit) {
+		return true
+	}
+
+	s.Reset()
+
+	return false
+}
+
+func acceptAny(t *parser.Tokeniser) bool {
+	if t.Peek() == -1 {
+		return false
+	}
+
+	t.Next()
+
+	return true
+}
+
+func ref(r *combinator.Rule[[]parser.Child]) combinator.Rule[[]parser.Child] {
+	return func(in *combinator.Input) ([]parser.Child, bool) {
+		return (*r)(in)
+	}
+}
+
+func node(typ parser.PhraseType, r combinator.Rule[[]parser.Child]) combinator.Rule[[]parser.Child] {
+	return func(in *combinator.Input) ([]parser.Child, bool) {
+		cs, ok := r(in)
+		if !ok {
+			return nil, false
+		}
+
+		n := &parser.Node{Type: typ, Children: cs}
+
+		if len(cs) > 0 {
+			n.Span = parser.Span{Start: cs[0].Span.Start, End: cs[len(cs)-1].Span.End}
+		} else {
+			n.Span = parser.Span{Start: in.End(), End: in.End()}
+		}
+
+		return []parser.Child{{Node: n, Span: n.Span}}, true
+	}
+}
+
+func tokenChild(r combinator.Rule[parser.Token]) combinator.Rule[[]parser.Child] {
+	return func(in *combinator.Input) ([]parser.Child, bool) {
+		start := in.Offset()
+
+		tk, ok := r(in)
+		if !ok {
+			return nil, false
+		}
+
+		return []parser.Child{{Token: tk, Span: parser.Span{Start: start, End: in.End()}}}, true
+	}
+}
+
+func flatten(css [][]parser.Child) []parser.Child {
+	return slices.Concat(css...)
+}
+
+func empty[T any](T) []parser.Child {
+	return nil
+}
+
+func isSkipped(typ parser.TokenType) bool {
+	switch typ {
+	case TokenWhitespace, TokenComment:
+		return true
+	}
+
+	return false
+}
+
+func notSkipped(tk parser.Token) bool {
+	return !isSkipped(tk.Type)
+}
+
+func terminal(r combinator.Rule[parser.Token]) combinator.Rule[[]parser.Child] {
+	return combinator.Map(combinator.Seq(skip, tokenChild(r)), flatten)
+}
+
+func literal(value string) combinator.Rule[[]parser.Child] {
+	return terminal(combinator.Match(strconv.Quote(value), func(tk parser.Token) bool {
+		return tk.Data == value && !isSkipped(tk.Type)
+	}))
+}
+
+func startRule(r combinator.Rule[[]parser.Child]) combinator.Rule[*parser.Node] {
+	seq := combinator.Map(combinator.Seq(r, skip), func(cs [][]parser.Child) *parser.Node {
+		root := cs[0][0].Node
+
+		return &parser.Node{
+			Type:     root.Type,
+			Children: append(slices.Clone(root.Children), cs[1]...),
+		}
+	})
+
+	return func(in *combinator.Input) (*parser.Node, bool) {
+		pos := in.Pos()
+
+		n, ok := seq(in)
+		if ok && in.Pos() == pos {
+			return nil, false
+		}
+
+		return n, ok
+	}
+}
+
+func phraseFunc(in *combinator.Input, typ parser.PhraseType, r combinator.Rule[*parser.Node]) parser.PhraseFunc {
+	match := combinator.PhraseFunc(in, typ, r)
+
+	var pf parser.PhraseFunc
+
+	pf = func(p *parser.Parser) (parser.Phrase, parser.PhraseFunc) {
+		if _, ok := end(in); ok {
+			return p.Done()
+		}
+
+		ph, next := match(p)
+		if ph.Type >= 0 {
+			next = pf
+		}
+
+		return ph, next
+	}
+
+	return pf
+}
+
+// Errors.
+var (
+	ErrNoTokenMatch = errors.New("no token rule matches")
+)
@@ -0,0 +1,90 @@
+package calc
+
+import (
+	"errors"
+	"io"
+	"os"
+	"reflect"
+	"testing"
+
+	"vimagination.zapto.org/parser"
+	"vimagination.zapto.org/parser/parsertest"
+	"vimagination.zapto.org/parser/peg"
+)
+
+const input = "a = 1.5 + (b - 2);\n# comment\nc;\n"
+
+func TestTokenise(t *testing.T) {
+	parsertest.CheckTokens(t, Names, "a = 1.5;# x", Tokenise, []parser.Token{
+		{Type: TokenIdent, Data: "a"},
+		{Type: TokenWhitespace, Data: " "},
+		{Type: TokenPunctuator, Data: "="},
+		{Type: TokenWhitespace, Data: " "},
+		{Type: TokenNumber, Data: "1.5"},
+		{Type: TokenPunctuator, Data: ";"},
+		{Type: TokenComment, Data: "# x"},
+		{Type: parser.TokenDone, Data: ""},
+	})
+}
+
+func TestMatchesInterpreter(t *testing.T) {
+	src, err := os.ReadFile("calc.peg")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	c, err := peg.Compile(string(src))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	for n, input := range [...]string{
+		input,
+		"a = ;",
+		"a = 1 $",
+	} {
+		expectedTokens := parsertest.Tokens(parser.NewStringTokeniser(input), c.Tokenise)
+		gotTokens := parsertest.Tokens(parser.NewStringTokeniser(input), Tokenise)
+
+		if !reflect.DeepEqual(gotTokens, expectedTokens) {
+			t.Errorf("test %d: expecting tokens %v, got %v", n+1, expectedTokens, gotTokens)
+		}
+
+		expected, expectedErr := c.Parser(parser.NewStringTokeniser(input)).GetPhrase()
+		got, gotErr := NewParser(parser.NewStringTokeniser(input)).GetPhrase()
+
+		if gotErr != nil || expectedErr != nil {
+			if gotErr == nil || expectedErr == nil || gotErr.Error() != expectedErr.Error() {
+				t.Errorf("test %d: expecting error %v, got %v", n+1, expectedErr, gotErr)
+			}
+		} else if !reflect.DeepEqual(got, expected) {
+			t.Errorf("test %d: expecting phrase %v, got %v", n+1, expected, got)
+		}
+	}
+}
+
+func TestParserSkippedOnly(t *testing.T) {
+	for n, test := range [...]struct {
+		Input   string
+		Phrases int
+	}{
+		{Input: ""},
+		{Input: "  \t\n"},
+		{Input: "# comment\n  # another"},
+		{Input: " a;\n # comment\n", Phrases: 1},
+	} {
+		p := NewParser(parser.NewStringTokeniser(test.Input))
+
+		for m := range test.Phrases {
+			if ph, err := p.GetPhrase(); err != nil {
+				t.Errorf("test %d.%d: unexpected error: %s", n+1, m+1, err)
+			} else if ph.Type != PhraseProgram {
+				t.Errorf("test %d.%d: expecting program phrase, got %d", n+1, m+1, ph.Type)
+			}
+		}
+
+		if ph, err := p.GetPhrase(); ph.Type != parser.PhraseDone || err != nil && !errors.Is(err, io.EOF) {
+			t.Errorf("test %d: expecting PhraseDone, got %v, %v", n+1, ph, err)
+		}
+	}
+}
@@ -0,0 +1,5 @@
+// Package calc is a simple calculator grammar, generated by parsergen, used to
+// test the generated code.
+package calc // import "vimagination.zapto.org/parser/cmd/parsergen/internal/calc"
+
+//go:generate go run vimagination.zapto.org/parser/cmd/parsergen -o calc.go calc.peg
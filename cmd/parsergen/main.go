@@ -0,0 +1,91 @@
+// Command parsergen generates Go source for a tokeniser and parser from a
+// grammar written in the format described by the peg package.
+//
+// Usage:
+//
+//	parsergen [-package name] [-prefix prefix] [-o output.go] grammar.peg
+//
+// The generated source contains TokenType and PhraseType constants for each of
+// the token rules, phrase rules and capture labels, a Names variable, a
+// Tokenise TokenFunc, and, when the grammar has phrase rules, a NewParser
+// function that creates a Parser using a PhraseFunc matching the first phrase
+// rule.
+//
+// The package name defaults to that of the $GOPACKAGE environment variable,
+// allowing the command to be used with go:generate:
+//
+//	//go:generate go run vimagination.zapto.org/parser/cmd/parsergen -o calc.go calc.peg
+//
+// When a prefix is given, it is added to every package-level identifier in the
+// generated source, allowing the code for multiple grammars to share a package.
+// Exported identifiers remain exported and unexported identifiers remain
+// unexported; for example, with a prefix of calc, Names becomes CalcNames,
+// TokenNumber becomes CalcTokenNumber and NewParser becomes CalcNewParser.
+//
+// When no output file is given, the source is written to stdout.
+package main // import "vimagination.zapto.org/parser/cmd/parsergen"
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"vimagination.zapto.org/parser/peg"
+)
+
+func main() {
+	if err := run(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	var output, pkg, prefix string
+
+	flag.StringVar(&output, "o", "", "output file (default stdout)")
+	flag.StringVar(&pkg, "package", os.Getenv("GOPACKAGE"), "package name for the generated code")
+	flag.StringVar(&prefix, "prefix", "", "prefix for the generated identifiers")
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		return ErrUsage
+	} else if pkg == "" {
+		return ErrNoPackage
+	}
+
+	input := flag.Arg(0)
+
+	src, err := os.ReadFile(input)
+	if err != nil {
+		return err
+	}
+
+	g, err := peg.Parse(string(src))
+	if err != nil {
+		return fmt.Errorf("%s:%w", input, err)
+	}
+
+	code, err := generate(pkg, prefix, filepath.Base(input), g)
+	if err != nil {
+		return fmt.Errorf("%s:%w", input, err)
+	}
+
+	if output == "" {
+		_, err = os.Stdout.Write(code)
+
+		return err
+	}
+
+	return os.WriteFile(output, code, 0o644)
+}
+
+// Errors.
+var (
+	ErrUsage         = errors.New("usage: parsergen [-package name] [-prefix prefix] [-o output.go] grammar.peg")
+	ErrNoPackage     = errors.New("no package name given")
+	ErrNameCollision = errors.New("names generate the same identifier")
+	ErrInvalidPrefix = errors.New("invalid identifier prefix")
+)
@@ -0,0 +1,464 @@
+// Package combinator provides parser combinators that operate on the Token
+// stream of a parser.Parser.
+package combinator // import "vimagination.zapto.org/parser/combinator"
+
+import (
+	"slices"
+	"strconv"
+
+	"vimagination.zapto.org/parser"
+)
+
+// Input wraps a parser.Parser, using its Token buffer to allow Rules to
+// backtrack.
+type Input struct {
+	// Names, which may be nil, is used to name TokenTypes in errors.
+	Names *parser.Names
+
+	// MemoLimit is the maximum number of results that will be cached for
+	// Rules created with Memo. Zero means no limit.
+	MemoLimit int
+
+	parser   *parser.Parser
+	base     int
+	fail     int
+	expected []string
+	memo     map[memoKey]memoEntry
+	order    []memoKey
+}
+
+// NewInput creates a new Input that reads Tokens from the given Parser.
+func NewInput(p *parser.Parser) *Input {
+	return &Input{parser: p}
+}
+
+// Pos returns the index of the next Token to be read, counted from the start
+// of the Token stream.
+func (in *Input) Pos() int {
+	return in.base + in.parser.Len()
+}
+
+// Offset returns the byte offset, within the input, of the next Token.
+func (in *Input) Offset() int {
+	return in.parser.Offset()
+}
+
+// End returns the byte offset, within the input, of the end of the last Token
+// read.
+func (in *Input) End() int {
+	return in.parser.End()
+}
+
+func (in *Input) peek() parser.Token {
+	return in.parser.Peek()
+}
+
+func (in *Input) next() parser.Token {
+	tk := in.parser.Peek()
+
+	if tk.Type >= 0 {
+		in.parser.Next()
+	}
+
+	return tk
+}
+
+func (in *Input) reset(pos int) {
+	n := pos - in.base
+
+	if l := in.parser.Len(); n < l {
+		in.parser.Backup(l - n)
+	} else {
+		for range n - l {
+			in.parser.Next()
+		}
+	}
+}
+
+func (in *Input) expect(pos int, expected string) {
+	if pos > in.fail {
+		in.fail = pos
+		in.expected = in.expected[:0]
+	} else if pos < in.fail || slices.Contains(in.expected, expected) {
+		return
+	}
+
+	in.expected = append(in.expected, expected)
+}
+
+// Err returns an error describing the furthest point in the Token stream at
+// which a Rule failed to match, and what was expected there.
+//
+// The returned error will be a *parser.UnexpectedTokenError, unless the Token
+// at that point is a TokenError, in which case the error of the Tokeniser is
+// returned.
+func (in *Input) Err() error {
+	pos := in.Pos()
+	in.reset(in.fail)
+	tk := in.peek()
+	offset := in.Offset()
+	in.reset(pos)
+
+	if tk.Type == parser.TokenError {
+		return in.parser.Err
+	}
+
+	return &parser.UnexpectedTokenError{
+		Expected: slices.Clone(in.expected),
+		Token:    tk,
+		Name:     in.Names.Token(tk.Type),
+		Offset:   offset,
+	}
+}
+
+func (in *Input) commit(pos int) {
+	in.base = pos
+	in.fail = pos
+	in.expected = in.expected[:0]
+
+	in.evictBefore(pos)
+}
+
+// Rule is a function that attempts to match a sequence of Tokens from the
+// Input, returning a typed result and whether the match was successful.
+//
+// When a Rule fails, the position of the Input is restored to where it was
+// before the Rule was called.
+type Rule[T any] func(*Input) (T, bool)
+
+// Token returns a Rule that matches a single Token of any of the given types.
+func Token(types ...parser.TokenType) Rule[parser.Token] {
+	return func(in *Input) (parser.Token, bool) {
+		pos := in.Pos()
+
+		if tk := in.peek(); slices.Contains(types, tk.Type) {
+			in.next()
+
+			return tk, true
+		}
+
+		for _, typ := range types {
+			in.expect(pos, in.Names.Token(typ))
+		}
+
+		return parser.Token{}, false
+	}
+}
+
+// Exact returns a Rule that matches a single Token that exactly matches one of
+// the given Tokens.
+func Exact(tokens ...parser.Token) Rule[parser.Token] {
+	return func(in *Input) (parser.Token, bool) {
+		pos := in.Pos()
+
+		if tk := in.peek(); slices.Contains(tokens, tk) {
+			in.next()
+
+			return tk, true
+		}
+
+		for _, tk := range tokens {
+			in.expect(pos, strconv.Quote(tk.Data))
+		}
+
+		return parser.Token{}, false
+	}
+}
+
+// Match returns a Rule that matches a single Token for which the given
+// function returns true, reporting the given description as what was expected
+// when it does not match.
+func Match(expected string, fn func(parser.Token) bool) Rule[parser.Token] {
+	return func(in *Input) (parser.Token, bool) {
+		pos := in.Pos()
+
+		if tk := in.peek(); tk.Type >= 0 && fn(tk) {
+			in.next()
+
+			return tk, true
+		}
+
+		in.expect(pos, expected)
+
+		return parser.Token{}, false
+	}
+}
+
+// Seq returns a Rule that matches each of the given Rules in order, returning
+// all of their results.
+func Seq[T any](rules ...Rule[T]) Rule[[]T] {
+	return func(in *Input) ([]T, bool) {
+		pos := in.Pos()
+		results := make([]T, 0, len(rules))
+
+		for _, r := range rules {
+			v, ok := r(in)
+			if !ok {
+				in.reset(pos)
+
+				return nil, false
+			}
+
+			results = append(results, v)
+		}
+
+		return results, true
+	}
+}
+
+// Choice returns a Rule that tries each of the given Rules in order, returning
+// the result of the first to match.
+func Choice[T any](rules ...Rule[T]) Rule[T] {
+	return func(in *Input) (T, bool) {
+		for _, r := range rules {
+			if v, ok := r(in); ok {
+				return v, true
+			}
+		}
+
+		var v T
+
+		return v, false
+	}
+}
+
+// Many returns a Rule that matches the given Rule as many times as possible,
+// including zero times.
+func Many[T any](r Rule[T]) Rule[[]T] {
+	return func(in *Input) ([]T, bool) {
+		var results []T
+
+		for {
+			pos := in.Pos()
+
+			v, ok := r(in)
+			if !ok || in.Pos() == pos {
+				return results, true
+			}
+
+			results = append(results, v)
+		}
+	}
+}
+
+// Many1 returns a Rule that matches the given Rule as many times as possible,
+// failing if it does not match at least once.
+func Many1[T any](r Rule[T]) Rule[[]T] {
+	many := Many(r)
+
+	return func(in *Input) ([]T, bool) {
+		first, ok := r(in)
+		if !ok {
+			return nil, false
+		}
+
+		rest, _ := many(in)
+
+		return append([]T{first}, rest...), true
+	}
+}
+
+// Optional returns a Rule that matches the given Rule, or matches nothing,
+// returning the zero value of T, if it does not match.
+func Optional[T any](r Rule[T]) Rule[T] {
+	return func(in *Input) (T, bool) {
+		v, _ := r(in)
+
+		return v, true
+	}
+}
+
+// SepBy returns a Rule that matches zero or more occurrences of the given Rule,
+// separated by the separator Rule, returning the results of the main Rule.
+func SepBy[T, S any](r Rule[T], sep Rule[S]) Rule[[]T] {
+	return func(in *Input) ([]T, bool) {
+		first, ok := r(in)
+		if !ok {
+			return nil, true
+		}
+
+		results := []T{first}
+
+		for {
+			pos := in.Pos()
+
+			if _, ok := sep(in); !ok {
+				return results, true
+			}
+
+			v, ok := r(in)
+			if !ok {
+				in.reset(pos)
+
+				return results, true
+			}
+
+			results = append(results, v)
+		}
+	}
+}
+
+// Between returns a Rule that matches the open, main and close Rules in order,
+// returning the result of the main Rule.
+func Between[O, T, C any](open Rule[O], r Rule[T], close Rule[C]) Rule[T] {
+	return func(in *Input) (T, bool) {
+		var zero T
+
+		pos := in.Pos()
+
+		if _, ok := open(in); !ok {
+			return zero, false
+		}
+
+		v, ok := r(in)
+		if !ok {
+			in.reset(pos)
+
+			return zero, false
+		}
+
+		if _, ok := close(in); !ok {
+			in.reset(pos)
+
+			return zero, false
+		}
+
+		return v, true
+	}
+}
+
+// Not returns a Rule that succeeds, without consuming any Tokens, only when the
+// given Rule does not match.
+func Not[T any](r Rule[T]) Rule[struct{}] {
+	return func(in *Input) (struct{}, bool) {
+		pos := in.Pos()
+		fail, expected := in.fail, slices.Clone(in.expected)
+
+		_, ok := r(in)
+
+		in.reset(pos)
+
+		in.fail, in.expected = fail, expected
+
+		if ok && pos > in.fail {
+			in.fail = pos
+			in.expected = in.expected[:0]
+		}
+
+		return struct{}{}, !ok
+	}
+}
+
+// Lookahead returns a Rule that matches the given Rule without consuming any
+// Tokens.
+func Lookahead[T any](r Rule[T]) Rule[T] {
+	return func(in *Input) (T, bool) {
+		pos := in.Pos()
+
+		v, ok := r(in)
+
+		in.reset(pos)
+
+		return v, ok
+	}
+}
+
+// Map returns a Rule that converts the result of the given Rule using the
+// given function.
+func Map[T, U any](r Rule[T], fn func(T) U) Rule[U] {
+	return func(in *Input) (U, bool) {
+		v, ok := r(in)
+		if !ok {
+			var u U
+
+			return u, false
+		}
+
+		return fn(v), true
+	}
+}
+
+// Label returns a Rule that, when the given Rule fails without matching any
+// Tokens, reports the given name as what was expected, instead of the
+// expectations of the Rules it contains.
+func Label[T any](name string, r Rule[T]) Rule[T] {
+	return func(in *Input) (T, bool) {
+		pos := in.Pos()
+		fail, expected := in.fail, slices.Clone(in.expected)
+
+		v, ok := r(in)
+		if !ok && in.fail == pos {
+			in.fail, in.expected = fail, expected
+
+			in.expect(pos, name)
+		}
+
+		return v, ok
+	}
+}
+
+// Silent returns a Rule that matches the given Rule without recording what it
+// expected when it fails, for Rules such as optional whitespace that should not
+// be mentioned in errors.
+func Silent[T any](r Rule[T]) Rule[T] {
+	return func(in *Input) (T, bool) {
+		fail, expected := in.fail, slices.Clone(in.expected)
+
+		v, ok := r(in)
+
+		in.fail, in.expected = fail, expected
+
+		return v, ok
+	}
+}
+
+// Lazy returns a Rule that calls the given function to retrieve the Rule to
+// match, allowing for recursive grammars.
+func Lazy[T any](fn func() Rule[T]) Rule[T] {
+	return func(in *Input) (T, bool) {
+		return fn()(in)
+	}
+}
+
+// PhraseFunc returns a PhraseFunc that matches the given Rule against the
+// Input, returning a Phrase of the given type that contains the matched
+// Tokens.
+//
+// When the result of the Rule is a *parser.Node, it is set as the Tree of the
+// Phrase, and given the Span of the Phrase.
+//
+// When there are no more Tokens the PhraseFunc returns Parser.Done, and when
+// the Rule fails to match the error returned by Input.Err is set on the Parser.
+//
+// The returned PhraseFunc must be used with the Parser that the Input was
+// created with.
+func PhraseFunc[T any](in *Input, typ parser.PhraseType, r Rule[T]) parser.PhraseFunc {
+	var pf parser.PhraseFunc
+
+	pf = func(p *parser.Parser) (parser.Phrase, parser.PhraseFunc) {
+		if tk := in.peek(); tk.Type == parser.TokenDone {
+			return p.Done()
+		} else if tk.Type == parser.TokenError {
+			return p.Error()
+		}
+
+		v, ok := r(in)
+		if !ok {
+			return p.ReturnError(in.Err())
+		}
+
+		pos := in.Pos()
+		ph, _ := p.Return(typ, pf)
+
+		in.commit(pos)
+
+		if tree, ok := any(v).(*parser.Node); ok {
+			ph.Tree = tree
+			tree.Span = ph.Span
+		}
+
+		return ph, pf
+	}
+
+	return pf
+}
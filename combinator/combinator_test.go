@@ -0,0 +1,249 @@
+package combinator
+
+import (
+	"errors"
+	"testing"
+
+	"vimagination.zapto.org/parser"
+)
+
+const (
+	tokenIdent parser.TokenType = iota
+	tokenNumber
+	tokenPunctuator
+)
+
+const phraseStatement parser.PhraseType = iota
+
+var names = &parser.Names{
+	Tokens: map[parser.TokenType]string{
+		tokenIdent:      "identifier",
+		tokenNumber:     "number",
+		tokenPunctuator: "punctuator",
+	},
+}
+
+func tokeniser(t *parser.Tokeniser) (parser.Token, parser.TokenFunc) {
+	if t.AcceptRun(" "); t.Len() > 0 {
+		t.Get()
+	}
+
+	switch {
+	case t.Peek() == -1:
+		return t.Done()
+	case t.Accept("0123456789"):
+		t.AcceptRun("0123456789")
+
+		return t.Return(tokenNumber, tokeniser)
+	case t.Accept("(),;="):
+		return t.Return(tokenPunctuator, tokeniser)
+	}
+
+	t.ExceptRun(" (),;=")
+
+	return t.Return(tokenIdent, tokeniser)
+}
+
+func input(str string) *Input {
+	p := parser.New(parser.NewStringTokeniser(str))
+
+	p.TokeniserState(tokeniser)
+
+	in := NewInput(&p)
+	in.Names = names
+
+	return in
+}
+
+func punctuator(c string) Rule[parser.Token] {
+	return Exact(parser.Token{Type: tokenPunctuator, Data: c})
+}
+
+func count[T any](v T) int {
+	return 1
+}
+
+func sum(vs []int) int {
+	var total int
+
+	for _, v := range vs {
+		total += v
+	}
+
+	return total
+}
+
+var value Rule[int]
+
+func init() {
+	value = Choice(
+		Map(Token(tokenIdent, tokenNumber), count),
+		Map(Between(punctuator("("), SepBy(Lazy(func() Rule[int] { return value }), punctuator(",")), punctuator(")")), sum),
+	)
+}
+
+func TestCombinators(t *testing.T) {
+	for n, test := range [...]struct {
+		Input string
+		Rule  Rule[int]
+		Count int
+		Match bool
+		Pos   int
+		Err   string
+	}{
+		{
+			Input: "a",
+			Rule:  value,
+			Count: 1,
+			Match: true,
+			Pos:   1,
+		},
+		{
+			Input: "(a, 1, (b, c), ())",
+			Rule:  value,
+			Count: 4,
+			Match: true,
+			Pos:   14,
+		},
+		{
+			Input: "(a, 1, (b, c), (;))",
+			Rule:  value,
+			Err:   "expected identifier, number, \"(\" or \")\", got punctuator \";\" at offset 16",
+		},
+		{
+			Input: "a b c 1 =",
+			Rule:  Map(Many(Token(tokenIdent)), func(tks []parser.Token) int { return len(tks) }),
+			Count: 3,
+			Match: true,
+			Pos:   3,
+		},
+		{
+			Input: "a b c 1 =",
+			Rule:  Map(Many1(Token(tokenIdent, tokenNumber)), func(tks []parser.Token) int { return len(tks) }),
+			Count: 4,
+			Match: true,
+			Pos:   4,
+		},
+		{
+			Input: "= a",
+			Rule:  Map(Many1(Token(tokenIdent)), func(tks []parser.Token) int { return len(tks) }),
+			Err:   "expected identifier, got punctuator \"=\" at offset 0",
+		},
+		{
+			Input: "a = 1",
+			Rule:  Map(Seq(Token(tokenIdent), Optional(punctuator("=")), Token(tokenNumber)), func(tks []parser.Token) int { return len(tks) }),
+			Count: 3,
+			Match: true,
+			Pos:   3,
+		},
+		{
+			Input: "a 1",
+			Rule:  Map(Seq(Token(tokenIdent), Optional(punctuator("=")), Token(tokenNumber)), func(tks []parser.Token) int { return len(tks) }),
+			Count: 3,
+			Match: true,
+			Pos:   2,
+		},
+		{
+			Input: "a ;",
+			Rule:  Map(Seq(Token(tokenIdent), Optional(punctuator("=")), Token(tokenNumber)), func(tks []parser.Token) int { return len(tks) }),
+			Err:   "expected \"=\" or number, got punctuator \";\" at offset 2",
+		},
+		{
+			Input: "a",
+			Rule:  Map(Seq(Token(tokenIdent), Token(tokenNumber)), func(tks []parser.Token) int { return len(tks) }),
+			Err:   "expected number, got TokenDone at offset 1",
+		},
+		{
+			Input: "a b",
+			Rule:  Map(Seq(Token(tokenIdent), Lookahead(Token(tokenIdent))), func(tks []parser.Token) int { return len(tks) }),
+			Count: 2,
+			Match: true,
+			Pos:   1,
+		},
+		{
+			Input: "a 1",
+			Rule:  Map(Seq(Map(Token(tokenIdent), count), Map(Not(Token(tokenIdent)), count)), sum),
+			Count: 2,
+			Match: true,
+			Pos:   1,
+		},
+		{
+			Input: "a b",
+			Rule:  Map(Seq(Map(Token(tokenIdent), count), Map(Not(Token(tokenIdent)), count)), sum),
+			Err:   "unexpected identifier \"b\" at offset 2",
+		},
+		{
+			Input: "a ;",
+			Rule:  Map(Seq(Map(Token(tokenIdent), count), Label("assignment", Map(Seq(punctuator("="), Token(tokenNumber)), count)), Map(Token(tokenNumber), count)), sum),
+			Err:   "expected assignment, got punctuator \";\" at offset 2",
+		},
+	} {
+		in := input(test.Input)
+
+		c, ok := test.Rule(in)
+
+		if ok != test.Match {
+			t.Errorf("test %d: expecting match %v, got %v", n+1, test.Match, ok)
+		} else if !ok {
+			if err := in.Err(); err.Error() != test.Err {
+				t.Errorf("test %d: expecting error %q, got %q", n+1, test.Err, err)
+			} else if in.Pos() != 0 {
+				t.Errorf("test %d: expecting position to be reset to 0, got %d", n+1, in.Pos())
+			}
+		} else if c != test.Count {
+			t.Errorf("test %d: expecting count %d, got %d", n+1, test.Count, c)
+		} else if in.Pos() != test.Pos {
+			t.Errorf("test %d: expecting position %d, got %d", n+1, test.Pos, in.Pos())
+		}
+	}
+}
+
+func TestPhraseFunc(t *testing.T) {
+	p := parser.New(parser.NewStringTokeniser("a = 1; b = (c, d); e = ;"))
+
+	p.TokeniserState(tokeniser)
+
+	in := NewInput(&p)
+	in.Names = names
+
+	p.PhraserState(PhraseFunc(in, phraseStatement, Seq(Map(Token(tokenIdent), count), Map(punctuator("="), count), value, Map(punctuator(";"), count))))
+
+	for n, expected := range [...]struct {
+		Tokens int
+		Span   parser.Span
+	}{
+		{4, parser.Span{Start: 0, End: 6}},
+		{8, parser.Span{Start: 6, End: 18}},
+	} {
+		if ph, err := p.GetPhrase(); err != nil {
+			t.Fatalf("test %d: unexpected error: %s", n+1, err)
+		} else if ph.Type != phraseStatement {
+			t.Errorf("test %d: expecting phrase type %d, got %d", n+1, phraseStatement, ph.Type)
+		} else if len(ph.Data) != expected.Tokens {
+			t.Errorf("test %d: expecting %d tokens, got %d", n+1, expected.Tokens, len(ph.Data))
+		} else if ph.Span != expected.Span {
+			t.Errorf("test %d: expecting span %v, got %v", n+1, expected.Span, ph.Span)
+		}
+	}
+
+	var e *parser.UnexpectedTokenError
+
+	if _, err := p.GetPhrase(); !errors.Is(err, parser.ErrUnexpectedToken) {
+		t.Errorf("test 3: expecting ErrUnexpectedToken, got %v", err)
+	} else if !errors.As(err, &e) {
+		t.Errorf("test 3: expecting UnexpectedTokenError, got %v", err)
+	} else if e.Offset != 23 {
+		t.Errorf("test 3: expecting error at offset 23, got %d", e.Offset)
+	}
+}
+
+func TestSilent(t *testing.T) {
+	in := input("a ;")
+	rule := Seq(Map(Token(tokenIdent), count), Map(Silent(Optional(punctuator("="))), count), Map(Token(tokenNumber), count))
+
+	if _, ok := rule(in); ok {
+		t.Fatalf("expecting no match")
+	} else if err := in.Err().Error(); err != "expected number, got punctuator \";\" at offset 2" {
+		t.Errorf("unexpected error: %s", err)
+	}
+}
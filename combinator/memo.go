@@ -0,0 +1,114 @@
+package combinator
+
+import "slices"
+
+type memoKey struct {
+	rule *int
+	pos  int
+}
+
+type memoEntry struct {
+	value    any
+	ok       bool
+	end      int
+	fail     int
+	expected []string
+}
+
+// Memo returns a Rule that caches the result of the given Rule for each
+// position in the Input, so that, however much backtracking occurs, the Rule is
+// run at most once per position, allowing linear time parsing of PEG
+// grammars.
+//
+// Cached results are discarded once the Tokens they were matched against have
+// been returned in a Phrase, and, when the Input has a MemoLimit, the oldest
+// results are discarded to keep the cache within that limit.
+//
+// Left-recursive Rules are not supported, as they recurse until the stack is
+// exhausted; grammars compiled by the peg package are checked for left
+// recursion.
+func Memo[T any](r Rule[T]) Rule[T] {
+	id := new(int)
+
+	return func(in *Input) (T, bool) {
+		key := memoKey{rule: id, pos: in.Pos()}
+
+		if e, ok := in.memo[key]; ok {
+			in.merge(e.fail, e.expected)
+
+			if !e.ok {
+				var v T
+
+				return v, false
+			}
+
+			in.reset(e.end)
+
+			return e.value.(T), true
+		}
+
+		fail, expected := in.fail, in.expected
+		in.fail, in.expected = -1, nil
+
+		v, ok := r(in)
+
+		e := memoEntry{
+			value:    v,
+			ok:       ok,
+			end:      in.Pos(),
+			fail:     in.fail,
+			expected: in.expected,
+		}
+
+		in.fail, in.expected = fail, expected
+
+		in.merge(e.fail, e.expected)
+		in.store(key, e)
+
+		return v, ok
+	}
+}
+
+func (in *Input) merge(fail int, expected []string) {
+	if fail < in.fail {
+		return
+	} else if fail > in.fail {
+		in.fail = fail
+		in.expected = in.expected[:0]
+	}
+
+	for _, e := range expected {
+		if !slices.Contains(in.expected, e) {
+			in.expected = append(in.expected, e)
+		}
+	}
+}
+
+func (in *Input) store(key memoKey, e memoEntry) {
+	if in.memo == nil {
+		in.memo = make(map[memoKey]memoEntry)
+	}
+
+	if in.MemoLimit > 0 {
+		for len(in.memo) >= in.MemoLimit && len(in.order) > 0 {
+			delete(in.memo, in.order[0])
+
+			in.order = in.order[1:]
+		}
+	}
+
+	in.memo[key] = e
+	in.order = append(in.order, key)
+}
+
+func (in *Input) evictBefore(pos int) {
+	for key := range in.memo {
+		if key.pos < pos {
+			delete(in.memo, key)
+		}
+	}
+
+	in.order = slices.DeleteFunc(in.order, func(key memoKey) bool {
+		return key.pos < pos
+	})
+}
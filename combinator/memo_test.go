@@ -0,0 +1,91 @@
+package combinator
+
+import (
+	"strings"
+	"testing"
+
+	"vimagination.zapto.org/parser"
+)
+
+func counted[T any](calls *int, r Rule[T]) Rule[T] {
+	return func(in *Input) (T, bool) {
+		*calls++
+
+		return r(in)
+	}
+}
+
+func TestMemo(t *testing.T) {
+	var calls int
+
+	ident := Memo(counted(&calls, Map(Many1(Token(tokenIdent)), func(tks []parser.Token) int { return len(tks) })))
+	rule := Choice(
+		Map(Seq(ident, Map(punctuator("="), count)), sum),
+		Map(Seq(ident, Map(punctuator(";"), count)), sum),
+		Map(Seq(ident, Map(punctuator(","), count)), sum),
+	)
+
+	in := input("a b c ,")
+
+	if c, ok := rule(in); !ok {
+		t.Fatalf("test 1: expecting match, got error %s", in.Err())
+	} else if c != 4 {
+		t.Errorf("test 1: expecting count 4, got %d", c)
+	} else if calls != 1 {
+		t.Errorf("test 1: expecting 1 call, got %d", calls)
+	}
+
+	calls = 0
+	in = input("a b c )")
+
+	if _, ok := rule(in); ok {
+		t.Fatalf("test 2: expecting no match")
+	} else if calls != 1 {
+		t.Errorf("test 2: expecting 1 call, got %d", calls)
+	} else if err := in.Err().Error(); err != "expected identifier, \"=\", \";\" or \",\", got punctuator \")\" at offset 6" {
+		t.Errorf("test 2: unexpected error: %s", err)
+	}
+
+	calls = 0
+	in = input("a b c ,")
+	in.MemoLimit = 1
+
+	other := Memo(Token(tokenIdent))
+	rule = Choice(
+		Map(Seq(ident, Map(other, count), Map(punctuator("="), count)), sum),
+		Map(Seq(ident, Map(punctuator(","), count)), sum),
+	)
+
+	if _, ok := rule(in); !ok {
+		t.Fatalf("test 3: expecting match, got error %s", in.Err())
+	} else if calls != 2 {
+		t.Errorf("test 3: expecting 2 calls, got %d", calls)
+	} else if len(in.memo) != 1 {
+		t.Errorf("test 3: expecting 1 cached entry, got %d", len(in.memo))
+	}
+}
+
+func TestMemoLinear(t *testing.T) {
+	var (
+		calls int
+		expr  Rule[int]
+	)
+
+	// Without memoisation, each level of nesting tries the inner expression
+	// twice, giving exponential behaviour.
+	expr = Memo(counted(&calls, Choice(
+		Map(Seq(Map(punctuator("("), count), Lazy(func() Rule[int] { return expr }), Map(punctuator(")"), count), Map(punctuator(";"), count)), sum),
+		Map(Seq(Map(punctuator("("), count), Lazy(func() Rule[int] { return expr }), Map(punctuator(")"), count)), sum),
+		Map(Token(tokenIdent), count),
+	)))
+
+	const depth = 20
+
+	in := input(strings.Repeat("(", depth) + "a" + strings.Repeat(")", depth))
+
+	if _, ok := expr(in); !ok {
+		t.Fatalf("expecting match, got error %s", in.Err())
+	} else if calls != depth+1 {
+		t.Errorf("expecting %d calls, got %d", depth+1, calls)
+	}
+}
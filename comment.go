@@ -0,0 +1,129 @@
+package parser
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// CommentKind represents the kind of comment read by Tokeniser.AcceptComment.
+type CommentKind uint8
+
+// Comment kinds.
+const (
+	NoComment CommentKind = iota
+	LineComment
+	BlockComment
+	DocLineComment
+	DocBlockComment
+)
+
+// CommentOptions determines which forms of comment are accepted by
+// Tokeniser.AcceptComment.
+type CommentOptions struct {
+	// Line contains the prefixes, such as "//", "#" or "--", that begin a
+	// comment that continues to the end of the line.
+	Line []string
+
+	// Open and Close are the delimiters of block comments, such as "/*" and
+	// "*/". Block comments are disabled when Open is empty.
+	Open, Close string
+
+	// Nested allows block comments to contain other block comments, which
+	// must also be closed.
+	Nested bool
+
+	// DocLine is a prefix, such as "///", that begins a line comment that is
+	// a doc comment. It must begin with one of the Line prefixes.
+	DocLine string
+
+	// DocBlock is an opening delimiter, such as "/**", that begins a block
+	// comment that is a doc comment. It must begin with Open.
+	DocBlock string
+}
+
+// AcceptComment reads a comment, in one of the forms enabled by the given
+// options, returning the kind of comment read, or NoComment, without reading
+// anything, if the input does not start with a comment.
+//
+// A line comment is read up to, but not including, the newline that ends it.
+//
+// A comment is only considered a doc comment when the doc prefix is not
+// followed by a repeat of its last character, so that, for example, "////"
+// and "/***" begin normal comments, and "/**/" is a normal, empty, block
+// comment.
+//
+// When a block comment is not closed before the end of the input, a
+// *SyntaxError wrapping ErrUnterminatedComment, with the offset of the end of
+// the input, is returned.
+func (t *Tokeniser) AcceptComment(opts CommentOptions) (CommentKind, error) {
+	if prefix := t.AcceptWord(opts.Line, false); prefix != "" {
+		kind := LineComment
+
+		if rest, ok := strings.CutPrefix(opts.DocLine, prefix); ok && rest != "" && t.AcceptString(rest, false) == len(rest) && !repeatsLast(t.Peek(), opts.DocLine) {
+			kind = DocLineComment
+		}
+
+		t.ExceptRun("\n")
+
+		return kind, nil
+	}
+
+	if opts.Open == "" || !t.acceptPrefix(opts.Open) {
+		return NoComment, nil
+	}
+
+	kind := BlockComment
+
+	if rest, ok := strings.CutPrefix(opts.DocBlock, opts.Open); ok && rest != "" && !t.hasPrefix(opts.Close) {
+		s := t.State()
+
+		if t.AcceptString(rest, false) == len(rest) && !repeatsLast(t.Peek(), opts.DocBlock) {
+			kind = DocBlockComment
+		}
+
+		s.Reset()
+	}
+
+	for depth := 1; depth > 0; {
+		if t.acceptPrefix(opts.Close) {
+			depth--
+		} else if opts.Nested && t.acceptPrefix(opts.Open) {
+			depth++
+		} else if t.Next() == -1 {
+			return kind, t.syntaxError(ErrUnterminatedComment)
+		}
+	}
+
+	return kind, nil
+}
+
+func repeatsLast(r rune, prefix string) bool {
+	last, _ := utf8.DecodeLastRuneInString(prefix)
+
+	return r == last
+}
+
+func (t *Tokeniser) acceptPrefix(prefix string) bool {
+	if first, _ := utf8.DecodeRuneInString(prefix); t.Peek() != first {
+		return false
+	}
+
+	s := t.State()
+
+	if t.AcceptString(prefix, false) == len(prefix) {
+		return true
+	}
+
+	s.Reset()
+
+	return false
+}
+
+func (t *Tokeniser) hasPrefix(prefix string) bool {
+	s := t.State()
+	ok := t.acceptPrefix(prefix)
+
+	s.Reset()
+
+	return ok
+}
@@ -0,0 +1,126 @@
+package parser
+
+import (
+	"errors"
+	"testing"
+)
+
+var rustComments = CommentOptions{
+	Line:     []string{"//"},
+	Open:     "/*",
+	Close:    "*/",
+	Nested:   true,
+	DocLine:  "///",
+	DocBlock: "/**",
+}
+
+func TestAcceptComment(t *testing.T) {
+	for n, test := range [...]struct {
+		Input   string
+		Options CommentOptions
+		Kind    CommentKind
+		Read    string
+		Err     error
+		Offset  int
+	}{
+		{
+			Input:   "// comment\nnext",
+			Options: rustComments,
+			Kind:    LineComment,
+			Read:    "// comment",
+		},
+		{
+			Input:   "/// doc\nnext",
+			Options: rustComments,
+			Kind:    DocLineComment,
+			Read:    "/// doc",
+		},
+		{
+			Input:   "//// not doc",
+			Options: rustComments,
+			Kind:    LineComment,
+			Read:    "//// not doc",
+		},
+		{
+			Input:   "/* a /* b */ c */ next",
+			Options: rustComments,
+			Kind:    BlockComment,
+			Read:    "/* a /* b */ c */",
+		},
+		{
+			Input:   "/* a /* b */ c */ next",
+			Options: CommentOptions{Open: "/*", Close: "*/"},
+			Kind:    BlockComment,
+			Read:    "/* a /* b */",
+		},
+		{
+			Input:   "/** doc */",
+			Options: rustComments,
+			Kind:    DocBlockComment,
+			Read:    "/** doc */",
+		},
+		{
+			Input:   "/**/ next",
+			Options: rustComments,
+			Kind:    BlockComment,
+			Read:    "/**/",
+		},
+		{
+			Input:   "/*** not doc */",
+			Options: rustComments,
+			Kind:    BlockComment,
+			Read:    "/*** not doc */",
+		},
+		{
+			Input:   "-- sql\n",
+			Options: CommentOptions{Line: []string{"#", "--"}, Open: "{-", Close: "-}", Nested: true},
+			Kind:    LineComment,
+			Read:    "-- sql",
+		},
+		{
+			Input:   "{- a {- b -} -}",
+			Options: CommentOptions{Line: []string{"--"}, Open: "{-", Close: "-}", Nested: true},
+			Kind:    BlockComment,
+			Read:    "{- a {- b -} -}",
+		},
+		{
+			Input:   "/ not a comment",
+			Options: rustComments,
+			Kind:    NoComment,
+		},
+		{
+			Input:   "/* a /* b */",
+			Options: rustComments,
+			Kind:    BlockComment,
+			Err:     ErrUnterminatedComment,
+			Offset:  12,
+		},
+		{
+			Input:   "/** a",
+			Options: rustComments,
+			Kind:    DocBlockComment,
+			Err:     ErrUnterminatedComment,
+			Offset:  5,
+		},
+	} {
+		for backend, tk := range tokenisers(test.Input) {
+			kind, err := tk.AcceptComment(test.Options)
+
+			var se *SyntaxError
+
+			if !errors.Is(err, test.Err) {
+				t.Errorf("test %d (%s): expecting error %v, got %v", n+1, backend, test.Err, err)
+			} else if kind != test.Kind {
+				t.Errorf("test %d (%s): expecting kind %d, got %d", n+1, backend, test.Kind, kind)
+			} else if err != nil {
+				if !errors.As(err, &se) {
+					t.Errorf("test %d (%s): expecting SyntaxError, got %T", n+1, backend, err)
+				} else if se.Offset != test.Offset {
+					t.Errorf("test %d (%s): expecting offset %d, got %d", n+1, backend, test.Offset, se.Offset)
+				}
+			} else if read := tk.Get(); read != test.Read {
+				t.Errorf("test %d (%s): expecting to read %q, got %q", n+1, backend, test.Read, read)
+			}
+		}
+	}
+}
@@ -0,0 +1,248 @@
+package parser
+
+import (
+	"io"
+	"slices"
+	"strings"
+)
+
+type trivia struct {
+	leading, trailing []Token
+}
+
+type heldToken struct {
+	token   Token
+	span    Span
+	leading []Token
+	ok      bool
+}
+
+// LosslessMode enables or disables the lossless concrete syntax tree mode,
+// which also enables tree mode; see TreeMode.
+//
+// In lossless mode, Tokens of the given trivia types, such as whitespace and
+// comments, are not returned by Peek, Next, Accept, etc., but are instead
+// attached to the surrounding Tokens in the tree: the trivia after a Token, up
+// to and including the first trivia Token that contains a newline, is added to
+// the Trailing trivia of that Token, and the remainder is added to the Leading
+// trivia of the next Token. At the end of the input, all remaining trivia is
+// added to the Trailing trivia of the last Token.
+//
+// For the tree to reproduce the input exactly, the TokenFunc must return all of
+// the input as Tokens, and the PhraseFunc must not retrieve Tokens directly
+// with Get.
+//
+// The mode should be set before any Tokens are read.
+func (p *Parser) LosslessMode(enabled bool, trivia ...TokenType) {
+	if enabled {
+		p.triviaTypes = slices.Clone(trivia)
+	} else {
+		p.triviaTypes = nil
+	}
+
+	p.TreeMode(enabled)
+}
+
+func (p *Parser) isTrivia(tk Token) bool {
+	return tk.Type >= 0 && slices.Contains(p.triviaTypes, tk.Type)
+}
+
+func (p *Parser) readLossless() Token {
+	var (
+		tk      Token
+		span    Span
+		leading []Token
+	)
+
+	if p.held.ok {
+		tk, span, leading = p.held.token, p.held.span, p.held.leading
+		p.held = heldToken{}
+	} else {
+		for tk, span = p.pull(); p.isTrivia(tk); tk, span = p.pull() {
+			leading = append(leading, tk)
+		}
+	}
+
+	var trailing []Token
+
+	if tk.Type >= 0 {
+		var after []Token
+
+		next, nextSpan := p.pull()
+
+		for ; p.isTrivia(next); next, nextSpan = p.pull() {
+			after = append(after, next)
+		}
+
+		split := len(after)
+
+		if next.Type != TokenDone {
+			split = lineEnd(after)
+		}
+
+		if split > 0 {
+			trailing = after[:split:split]
+		}
+
+		p.held = heldToken{token: next, span: nextSpan, ok: true}
+
+		if split < len(after) {
+			p.held.leading = after[split:]
+		}
+	}
+
+	p.push(tk, span, trivia{leading: leading, trailing: trailing})
+
+	return tk
+}
+
+func lineEnd(tokens []Token) int {
+	for n, tk := range tokens {
+		if strings.Contains(tk.Data, "\n") {
+			return n + 1
+		}
+	}
+
+	return len(tokens)
+}
+
+func (p *Parser) remainingTrivia() *Node {
+	if len(p.triviaTypes) == 0 || !p.finished() {
+		return nil
+	}
+
+	last := len(p.tokens) - 1
+	leading := p.trivia[last].leading
+
+	if len(leading) == 0 {
+		return nil
+	}
+
+	p.trivia[last].leading = nil
+
+	return &Node{
+		Type: PhraseDone,
+		Children: []Child{
+			{Token: p.tokens[last], Span: p.spans[last], Leading: leading},
+		},
+		Span: Span{End: p.spans[last].End},
+	}
+}
+
+// WriteTo writes the Data of all of the Tokens in the tree, including any
+// trivia, to the given Writer.
+//
+// For a tree built in lossless mode, this reproduces the input of the tree.
+func (n *Node) WriteTo(w io.Writer) (int64, error) {
+	var total int64
+
+	for _, c := range n.Children {
+		var (
+			m   int64
+			err error
+		)
+
+		if c.Node != nil {
+			m, err = c.Node.WriteTo(w)
+		} else {
+			m, err = writeChild(w, c)
+		}
+
+		total += m
+
+		if err != nil {
+			return total, err
+		}
+	}
+
+	return total, nil
+}
+
+func writeChild(w io.Writer, c Child) (int64, error) {
+	var total int64
+
+	for _, tk := range slices.Concat(c.Leading, []Token{c.Token}, c.Trailing) {
+		if tk.Type < 0 {
+			continue
+		}
+
+		m, err := io.WriteString(w, tk.Data)
+		total += int64(m)
+
+		if err != nil {
+			return total, err
+		}
+	}
+
+	return total, nil
+}
+
+// String returns the text of the tree, as written by WriteTo.
+func (n *Node) String() string {
+	var sb strings.Builder
+
+	n.WriteTo(&sb)
+
+	return sb.String()
+}
+
+// Replace replaces the sub-Node from, found anywhere in the tree, with the
+// Node to, returning true if from was found.
+//
+// The Leading trivia of the first Token of from and the Trailing trivia of the
+// last Token of from are moved to the first and last Tokens of to,
+// respectively, unless those Tokens already have trivia, so that the
+// formatting surrounding the replaced Node is kept.
+//
+// The Spans of the tree are not updated.
+func (n *Node) Replace(from, to *Node) bool {
+	for i := range n.Children {
+		c := &n.Children[i]
+
+		if c.Node == from {
+			moveTrivia(from, to)
+
+			c.Node = to
+
+			return true
+		} else if c.Node != nil && c.Node.Replace(from, to) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func moveTrivia(from, to *Node) {
+	if src, dst := from.first(), to.first(); src != nil && dst != nil && len(dst.Leading) == 0 {
+		dst.Leading = src.Leading
+	}
+
+	if src, dst := from.last(), to.last(); src != nil && dst != nil && len(dst.Trailing) == 0 {
+		dst.Trailing = src.Trailing
+	}
+}
+
+func (n *Node) first() *Child {
+	for i := range n.Children {
+		if c := &n.Children[i]; c.Node == nil {
+			return c
+		} else if f := c.Node.first(); f != nil {
+			return f
+		}
+	}
+
+	return nil
+}
+
+func (n *Node) last() *Child {
+	for i := len(n.Children) - 1; i >= 0; i-- {
+		if c := &n.Children[i]; c.Node == nil {
+			return c
+		} else if l := c.Node.last(); l != nil {
+			return l
+		}
+	}
+
+	return nil
+}
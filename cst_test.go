@@ -0,0 +1,172 @@
+package parser
+
+import (
+	"reflect"
+	"testing"
+)
+
+func cstParser(p *Parser) (Phrase, PhraseFunc) {
+	if p.Peek().Type == TokenDone {
+		return p.Done()
+	}
+
+	for {
+		switch p.Peek().Type {
+		case fixtureOpen:
+			p.OpenNode(treeList)
+			p.Next()
+		case fixtureClose:
+			p.Next()
+			p.CloseNode()
+		case TokenDone:
+			return p.Return(treeRoot, cstParser)
+		default:
+			p.Next()
+		}
+	}
+}
+
+func newCSTParser(input string) Parser {
+	p := New(NewStringTokeniser(input))
+
+	p.TokeniserState(fixtureTokeniser)
+	p.PhraserState(cstParser)
+	p.LosslessMode(true, fixtureWhitespace, fixtureComment)
+
+	return p
+}
+
+func TestLosslessPrint(t *testing.T) {
+	for n, test := range [...]string{
+		"",
+		"a",
+		"  a  ",
+		"a (b c) d",
+		"# comment\n(a # trailing\n  b)\n\n# final\n",
+		"(a(b(c)))   ",
+		"   \n# only trivia\n",
+	} {
+		p := newCSTParser(test)
+
+		ph, err := p.GetPhrase()
+		if err != nil {
+			t.Errorf("test %d: unexpected error: %s", n+1, err)
+		} else if ph.Tree == nil {
+			if test != "" {
+				t.Errorf("test %d: expecting tree, got nil", n+1)
+			}
+		} else if str := ph.Tree.String(); str != test {
+			t.Errorf("test %d: expecting to print %q, got %q", n+1, test, str)
+		}
+	}
+}
+
+func TestLosslessTrivia(t *testing.T) {
+	p := newCSTParser("# lead\na # trail\n  # next\nb c  ")
+
+	ph, err := p.GetPhrase()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := []Child{
+		{
+			Token:    Token{Type: fixtureWord, Data: "a"},
+			Span:     Span{Start: 7, End: 8},
+			Leading:  []Token{{Type: fixtureComment, Data: "# lead"}, {Type: fixtureWhitespace, Data: "\n"}},
+			Trailing: []Token{{Type: fixtureWhitespace, Data: " "}, {Type: fixtureComment, Data: "# trail"}, {Type: fixtureWhitespace, Data: "\n  "}},
+		},
+		{
+			Token:    Token{Type: fixtureWord, Data: "b"},
+			Span:     Span{Start: 26, End: 27},
+			Leading:  []Token{{Type: fixtureComment, Data: "# next"}, {Type: fixtureWhitespace, Data: "\n"}},
+			Trailing: []Token{{Type: fixtureWhitespace, Data: " "}},
+		},
+		{
+			Token:    Token{Type: fixtureWord, Data: "c"},
+			Span:     Span{Start: 28, End: 29},
+			Trailing: []Token{{Type: fixtureWhitespace, Data: "  "}},
+		},
+	}
+
+	if !reflect.DeepEqual(ph.Tree.Children, expected) {
+		t.Errorf("expecting children %v, got %v", expected, ph.Tree.Children)
+	}
+
+	if expectedData := []Token{{Type: fixtureWord, Data: "a"}, {Type: fixtureWord, Data: "b"}, {Type: fixtureWord, Data: "c"}}; !reflect.DeepEqual(ph.Data, expectedData) {
+		t.Errorf("expecting data %v, got %v", expectedData, ph.Data)
+	}
+}
+
+func TestLosslessReplace(t *testing.T) {
+	for n, test := range [...]struct {
+		Input, Replacement, Output string
+	}{
+		{
+			Input:       "a (b c) d",
+			Replacement: "(e)",
+			Output:      "a (e) d",
+		},
+		{
+			Input:       "a\n  (b # comment\n  c) # end\nd",
+			Replacement: "(e f)",
+			Output:      "a\n  (e f) # end\nd",
+		},
+		{
+			Input:       "a\n  (b # comment\n  c) # end\nd",
+			Replacement: "(e f)\n",
+			Output:      "a\n  (e f)\nd",
+		},
+		{
+			Input:       "(a (b) c)",
+			Replacement: "(d (e) f)",
+			Output:      "(d (e) f)",
+		},
+	} {
+		p := newCSTParser(test.Input)
+
+		ph, err := p.GetPhrase()
+		if err != nil {
+			t.Errorf("test %d: unexpected error: %s", n+1, err)
+
+			continue
+		}
+
+		old := firstList(ph.Tree)
+
+		r := newCSTParser(test.Replacement)
+
+		rph, err := r.GetPhrase()
+		if err != nil {
+			t.Errorf("test %d: unexpected error: %s", n+1, err)
+
+			continue
+		}
+
+		if !ph.Tree.Replace(old, firstList(rph.Tree)) {
+			t.Errorf("test %d: expecting replacement to succeed", n+1)
+		} else if str := ph.Tree.String(); str != test.Output {
+			t.Errorf("test %d: expecting to print %q, got %q", n+1, test.Output, str)
+		}
+
+		if ph.Tree.Replace(old, old) {
+			t.Errorf("test %d: expecting replaced Node to not be found", n+1)
+		}
+	}
+}
+
+func firstList(n *Node) *Node {
+	for _, c := range n.Children {
+		if c.Node != nil {
+			if c.Node.Type == treeList {
+				return c.Node
+			}
+
+			if l := firstList(c.Node); l != nil {
+				return l
+			}
+		}
+	}
+
+	return nil
+}
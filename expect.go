@@ -0,0 +1,156 @@
+package parser
+
+import (
+	"strconv"
+	"strings"
+)
+
+type expectation struct {
+	token Token
+	exact bool
+}
+
+func (p *Parser) expect(e expectation) {
+	at := p.base + p.pos
+
+	if at < p.expectedAt {
+		return
+	} else if at > p.expectedAt {
+		p.expectedAt = at
+		p.expected = p.expected[:0]
+	}
+
+	for _, f := range p.expected {
+		if f == e {
+			return
+		}
+	}
+
+	p.expected = append(p.expected, e)
+}
+
+func (p *Parser) expectTypes(types []TokenType) {
+	for _, typ := range types {
+		p.expect(expectation{token: Token{Type: typ}})
+	}
+}
+
+func (p *Parser) expectTokens(tokens []Token) {
+	for _, tk := range tokens {
+		p.expect(expectation{token: tk, exact: true})
+	}
+}
+
+// Expect accepts a Token with one of the given types, returning it.
+//
+// If the next Token does not have one of the given types, it is not read, and
+// the error returned is the result of Unexpected.
+func (p *Parser) Expect(types ...TokenType) (Token, error) {
+	if p.Accept(types...) {
+		return p.tokens[p.pos-1], nil
+	}
+
+	return Token{}, p.Unexpected()
+}
+
+// ExpectToken accepts a Token matching one of the given Tokens exactly,
+// returning it.
+//
+// If the next Token does not match, it is not read, and the error returned is
+// the result of Unexpected.
+func (p *Parser) ExpectToken(tokens ...Token) (Token, error) {
+	if p.AcceptToken(tokens...) {
+		return p.tokens[p.pos-1], nil
+	}
+
+	return Token{}, p.Unexpected()
+}
+
+// Unexpected returns an error describing the next Token as unexpected.
+//
+// The returned error will be an *UnexpectedTokenError listing what was
+// expected at the position of the next Token, as recorded by failed calls to
+// Accept, AcceptToken, Expect and ExpectToken. This allows the expectations of
+// alternatives tried at the same position to be combined into a single error.
+//
+// If the next Token is a TokenError, the error of the Tokeniser is returned
+// instead.
+func (p *Parser) Unexpected() error {
+	tk := p.Peek()
+
+	if tk.Type == TokenError {
+		return p.Err
+	}
+
+	err := &UnexpectedTokenError{
+		Token:  tk,
+		Name:   p.Names.Token(tk.Type),
+		Offset: p.Offset(),
+	}
+
+	if p.expectedAt == p.base+p.pos {
+		for _, e := range p.expected {
+			if e.exact {
+				err.Expected = append(err.Expected, strconv.Quote(e.token.Data))
+			} else {
+				err.Expected = append(err.Expected, p.Names.Token(e.token.Type))
+			}
+		}
+	}
+
+	return err
+}
+
+// UnexpectedTokenError is returned by Unexpected, and the Expect methods, to
+// describe an unexpected Token and what was expected in its place. It is also
+// returned by the combinator and pratt packages.
+//
+// Offset is the byte offset of the Token within the input.
+type UnexpectedTokenError struct {
+	Expected []string
+	Token    Token
+	Name     string
+	Offset   int
+}
+
+// Error implements the error interface.
+func (u *UnexpectedTokenError) Error() string {
+	var sb strings.Builder
+
+	if len(u.Expected) > 0 {
+		sb.WriteString("expected ")
+
+		for n, expected := range u.Expected {
+			if n > 0 {
+				if n == len(u.Expected)-1 {
+					sb.WriteString(" or ")
+				} else {
+					sb.WriteString(", ")
+				}
+			}
+
+			sb.WriteString(expected)
+		}
+
+		sb.WriteString(", got ")
+	} else {
+		sb.WriteString("unexpected ")
+	}
+
+	sb.WriteString(u.Name)
+
+	if u.Token.Type >= 0 {
+		sb.WriteString(" ")
+		sb.WriteString(strconv.Quote(u.Token.Data))
+	}
+
+	sb.WriteString(" at offset ")
+	sb.WriteString(strconv.Itoa(u.Offset))
+
+	return sb.String()
+}
+
+// Unwrap returns the underlying ErrUnexpectedToken error.
+func (u *UnexpectedTokenError) Unwrap() error {
+	return ErrUnexpectedToken
+}
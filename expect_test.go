@@ -0,0 +1,70 @@
+package parser
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestExpect(t *testing.T) {
+	p := fixtureParser("a  = ;")
+
+	if tk, err := p.Expect(fixtureWord); err != nil {
+		t.Fatalf("test 1: unexpected error: %s", err)
+	} else if tk != (Token{Type: fixtureWord, Data: "a"}) {
+		t.Errorf("test 1: expecting word token, got %v", tk)
+	}
+
+	if p.Accept(fixtureNumber) {
+		t.Errorf("test 2: expecting no number")
+	} else if err := p.Unexpected(); err.Error() != "expected number, got assign \"=\" at offset 3" {
+		t.Errorf("test 2: unexpected error: %s", err)
+	} else if _, err := p.ExpectToken(Token{Type: fixtureAssign, Data: "="}); err != nil {
+		t.Errorf("test 3: unexpected error: %s", err)
+	}
+
+	var u *UnexpectedTokenError
+
+	if p.Accept(fixtureWord) || p.AcceptToken(Token{Type: fixtureOpen, Data: "("}) || p.Accept(fixtureWord) {
+		t.Errorf("test 4: expecting no token to be accepted")
+	} else if _, err := p.Expect(fixtureNumber); !errors.As(err, &u) {
+		t.Errorf("test 4: expecting UnexpectedTokenError, got %v", err)
+	} else if err.Error() != "expected word, \"(\" or number, got semicolon \";\" at offset 5" {
+		t.Errorf("test 4: unexpected error: %s", err)
+	} else if u.Offset != 5 || u.Token != (Token{Type: fixtureSemi, Data: ";"}) {
+		t.Errorf("test 4: unexpected error values: %v", u)
+	} else if !errors.Is(err, ErrUnexpectedToken) {
+		t.Errorf("test 4: expecting error to wrap ErrUnexpectedToken")
+	} else if p.Len() != 2 {
+		t.Errorf("test 4: expecting Len 2, got %d", p.Len())
+	}
+
+	p.Next()
+
+	if err := p.Unexpected(); err.Error() != "unexpected TokenDone at offset 6" {
+		t.Errorf("test 5: unexpected error: %s", err)
+	}
+
+	p = fixtureParser("a !")
+
+	p.Next()
+
+	if _, err := p.Expect(fixtureNumber); !errors.Is(err, ErrUnknownError) {
+		t.Errorf("test 6: expecting ErrUnknownError, got %v", err)
+	}
+}
+
+func TestParserOffset(t *testing.T) {
+	p := fixtureParser("ab  12 (  c")
+
+	for n, expected := range [...]int{0, 4, 7, 10, 11, 11} {
+		if offset := p.Offset(); offset != expected {
+			t.Errorf("test %d: expecting offset %d, got %d", n+1, expected, offset)
+		}
+
+		p.Next()
+
+		if n == 2 {
+			p.Get()
+		}
+	}
+}
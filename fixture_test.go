@@ -0,0 +1,90 @@
+package parser
+
+const (
+	fixtureWord TokenType = iota
+	fixtureNumber
+	fixtureOpen
+	fixtureClose
+	fixtureComma
+	fixtureAssign
+	fixtureSemi
+	fixtureWhitespace
+	fixtureComment
+)
+
+var fixtureNames = &Names{
+	Tokens: map[TokenType]string{
+		fixtureWord:       "word",
+		fixtureNumber:     "number",
+		fixtureOpen:       "open",
+		fixtureClose:      "close",
+		fixtureComma:      "comma",
+		fixtureAssign:     "assign",
+		fixtureSemi:       "semicolon",
+		fixtureWhitespace: "whitespace",
+		fixtureComment:    "comment",
+	},
+}
+
+// fixtureTokeniser is the TokenFunc shared by the tests of the Parser helpers.
+//
+// Runs of spaces, tabs and newlines are returned as whitespace Tokens, a '#'
+// starts a comment that runs to the end of the line, and a '!' is an error.
+func fixtureTokeniser(t *Tokeniser) (Token, TokenFunc) {
+	switch {
+	case t.Peek() == -1:
+		return t.Done()
+	case t.Accept(" \t\n"):
+		t.AcceptRun(" \t\n")
+
+		return t.Return(fixtureWhitespace, fixtureTokeniser)
+	case t.Accept("#"):
+		t.ExceptRun("\n")
+
+		return t.Return(fixtureComment, fixtureTokeniser)
+	case t.Accept("0123456789"):
+		t.AcceptRun("0123456789")
+
+		return t.Return(fixtureNumber, fixtureTokeniser)
+	case t.Accept("("):
+		return t.Return(fixtureOpen, fixtureTokeniser)
+	case t.Accept(")"):
+		return t.Return(fixtureClose, fixtureTokeniser)
+	case t.Accept(","):
+		return t.Return(fixtureComma, fixtureTokeniser)
+	case t.Accept("="):
+		return t.Return(fixtureAssign, fixtureTokeniser)
+	case t.Accept(";"):
+		return t.Return(fixtureSemi, fixtureTokeniser)
+	case t.Accept("!"):
+		return t.ReturnError(ErrUnknownError)
+	}
+
+	t.ExceptRun(" \t\n#(),=;!")
+
+	return t.Return(fixtureWord, fixtureTokeniser)
+}
+
+// fixtureSkipper is fixtureTokeniser with the whitespace discarded.
+func fixtureSkipper(t *Tokeniser) (Token, TokenFunc) {
+	if t.AcceptRun(" \t\n"); t.Len() > 0 {
+		t.Get()
+	}
+
+	tk, next := fixtureTokeniser(t)
+	if tk.Type < 0 {
+		return tk, next
+	}
+
+	return tk, fixtureSkipper
+}
+
+// fixtureParser creates a Parser, using fixtureSkipper, for the given input.
+func fixtureParser(input string) *Parser {
+	p := New(NewStringTokeniser(input))
+	p.Names = fixtureNames
+
+	p.TokeniserState(fixtureSkipper)
+
+	return &p
+}
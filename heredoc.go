@@ -0,0 +1,254 @@
+package parser
+
+import (
+	"strings"
+	"unicode"
+)
+
+// HeredocIndent determines how indentation is removed from the body of a
+// heredoc.
+type HeredocIndent uint8
+
+// Heredoc indentation modes.
+const (
+	// IndentKeep keeps the body unchanged, and requires the delimiter to be
+	// at the very start of its line.
+	IndentKeep HeredocIndent = iota
+
+	// IndentTabs removes leading tabs from each line of the body, and from
+	// the delimiter line, as with <<- in shell.
+	IndentTabs
+
+	// IndentCommon removes the smallest indentation of the non-blank lines
+	// of the body from each line, and allows the delimiter to be indented,
+	// as with <<~ in Ruby.
+	IndentCommon
+)
+
+// HeredocOptions determines the form of heredoc introducer accepted by
+// Tokeniser.AcceptHeredoc.
+type HeredocOptions struct {
+	// Introducer begins a heredoc, such as "<<".
+	Introducer string
+
+	// Tabs and Common enable the '-' and '~' modifiers, respectively, after
+	// the Introducer, which select IndentTabs and IndentCommon.
+	Tabs, Common bool
+
+	// Quotes contains the characters that may be used to quote the
+	// delimiter.
+	Quotes string
+}
+
+// Heredoc is a heredoc introduced by Tokeniser.AcceptHeredoc.
+type Heredoc struct {
+	// Delimiter is the string that terminates the heredoc.
+	Delimiter string
+
+	// Quoted is true when the delimiter was quoted, which, in most
+	// languages, disables interpolation within the body.
+	Quoted bool
+
+	// Indent is the indentation mode of the heredoc.
+	Indent HeredocIndent
+
+	// Body is the contents of the heredoc, with indentation removed, which
+	// is set by Tokeniser.AcceptHeredocBody.
+	Body string
+}
+
+// AcceptHeredoc reads a heredoc introducer, such as <<EOF, <<-'EOF' or
+// <<~"EOF", adding the introduced heredoc to a queue of heredocs whose bodies
+// are to be read, in order, by AcceptHeredocBody, once the rest of the line
+// has been read.
+//
+// An unquoted delimiter is made up of letters, digits and underscores.
+//
+// If the input does not start with the Introducer, nothing is read and an
+// error wrapping ErrNotHeredoc is returned.
+func (t *Tokeniser) AcceptHeredoc(opts HeredocOptions) (Heredoc, error) {
+	var h Heredoc
+
+	if !t.acceptPrefix(opts.Introducer) {
+		return h, t.syntaxError(ErrNotHeredoc)
+	}
+
+	if opts.Tabs && t.AcceptRune('-') {
+		h.Indent = IndentTabs
+	} else if opts.Common && t.AcceptRune('~') {
+		h.Indent = IndentCommon
+	}
+
+	if q := t.Peek(); q >= 0 && strings.ContainsRune(opts.Quotes, q) {
+		t.Next()
+
+		var sb strings.Builder
+
+		for r := t.Peek(); r != q; r = t.Peek() {
+			if r == -1 || r == '\n' {
+				return h, t.syntaxError(ErrMissingDelimiter)
+			}
+
+			sb.WriteRune(t.Next())
+		}
+
+		t.Next()
+
+		h.Delimiter = sb.String()
+		h.Quoted = true
+	} else {
+		var sb strings.Builder
+
+		for r := t.Peek(); r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r); r = t.Peek() {
+			sb.WriteRune(t.Next())
+		}
+
+		h.Delimiter = sb.String()
+	}
+
+	if h.Delimiter == "" {
+		return h, t.syntaxError(ErrMissingDelimiter)
+	}
+
+	t.heredocs = append(t.heredocs, h)
+
+	return h, nil
+}
+
+// PendingHeredocs returns the number of heredocs that have been introduced,
+// but whose bodies have not been read.
+func (t *Tokeniser) PendingHeredocs() int {
+	return len(t.heredocs)
+}
+
+// AcceptHeredocBody reads the body of the first pending heredoc, from the
+// start of the current line up to and including the line containing its
+// delimiter, returning the heredoc with its Body set.
+//
+// When there are several pending heredocs, as in `cat <<A <<B`, this should
+// be called once for each, after the newline ending the line that introduced
+// them; each body directly follows the previous one.
+//
+// Returns an error wrapping ErrNoHeredoc if there are no pending heredocs.
+func (t *Tokeniser) AcceptHeredocBody() (Heredoc, error) {
+	if len(t.heredocs) == 0 {
+		return Heredoc{}, t.syntaxError(ErrNoHeredoc)
+	}
+
+	h := t.heredocs[0]
+	t.heredocs = t.heredocs[1:]
+
+	var indent string
+
+	switch h.Indent {
+	case IndentTabs:
+		indent = "\t"
+	case IndentCommon:
+		indent = " \t"
+	}
+
+	lines, err := t.acceptLines(h.Delimiter, indent)
+	if err != nil {
+		return h, err
+	}
+
+	switch h.Indent {
+	case IndentTabs:
+		for n, line := range lines {
+			lines[n] = strings.TrimLeft(line, "\t")
+		}
+	case IndentCommon:
+		removeCommonIndent(lines)
+	}
+
+	h.Body = strings.Join(lines, "")
+
+	return h, nil
+}
+
+// AcceptUntil reads up to, and including, the given delimiter, returning the
+// data read before it.
+//
+// When lineOnly is true, the delimiter only matches when it makes up an entire
+// line, and the newline following it is also read.
+//
+// This can be used for strings with delimiters chosen by the input, such as
+// the $tag$ strings of PostgreSQL. If the delimiter is not found, a
+// *SyntaxError wrapping ErrUnterminatedString is returned.
+func (t *Tokeniser) AcceptUntil(delim string, lineOnly bool) (string, error) {
+	if lineOnly {
+		lines, err := t.acceptLines(delim, "")
+
+		return strings.Join(lines, ""), err
+	}
+
+	var sb strings.Builder
+
+	for !t.acceptPrefix(delim) {
+		if t.Peek() == -1 {
+			return sb.String(), t.syntaxError(ErrUnterminatedString)
+		}
+
+		sb.WriteRune(t.Next())
+	}
+
+	return sb.String(), nil
+}
+
+func (t *Tokeniser) acceptLines(delim, indent string) ([]string, error) {
+	var lines []string
+
+	for {
+		s := t.State()
+
+		t.AcceptRun(indent)
+
+		if t.acceptPrefix(delim) {
+			if r := t.Peek(); r == '\n' || r == -1 {
+				t.Accept("\n")
+
+				return lines, nil
+			}
+		}
+
+		s.Reset()
+
+		if t.Peek() == -1 {
+			return lines, t.syntaxError(ErrUnterminatedString)
+		}
+
+		var sb strings.Builder
+
+		for r := t.Next(); r != -1; r = t.Next() {
+			sb.WriteRune(r)
+
+			if r == '\n' {
+				break
+			}
+		}
+
+		lines = append(lines, sb.String())
+	}
+}
+
+func removeCommonIndent(lines []string) {
+	common := -1
+
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		if n := len(line) - len(strings.TrimLeft(line, " \t")); common == -1 || n < common {
+			common = n
+		}
+	}
+
+	if common <= 0 {
+		return
+	}
+
+	for n, line := range lines {
+		lines[n] = line[min(common, len(line)-len(strings.TrimLeft(line, " \t"))):]
+	}
+}
@@ -0,0 +1,256 @@
+package parser
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+var shellHeredocs = HeredocOptions{
+	Introducer: "<<",
+	Tabs:       true,
+	Quotes:     "'\"",
+}
+
+var rubyHeredocs = HeredocOptions{
+	Introducer: "<<",
+	Tabs:       true,
+	Common:     true,
+	Quotes:     "'\"",
+}
+
+func TestAcceptHeredoc(t *testing.T) {
+	for n, test := range [...]struct {
+		Input   string
+		Options HeredocOptions
+		Heredoc Heredoc
+		Read    string
+		Err     error
+		Offset  int
+	}{
+		{
+			Input:   "<<EOF\nabc\n$def\nEOF\nrest",
+			Options: shellHeredocs,
+			Heredoc: Heredoc{Delimiter: "EOF", Body: "abc\n$def\n"},
+			Read:    "<<EOF\nabc\n$def\nEOF\n",
+		},
+		{
+			Input:   "<<'EOF'\nabc\n EOF\nEOFX\nEOF",
+			Options: shellHeredocs,
+			Heredoc: Heredoc{Delimiter: "EOF", Quoted: true, Body: "abc\n EOF\nEOFX\n"},
+			Read:    "<<'EOF'\nabc\n EOF\nEOFX\nEOF",
+		},
+		{
+			Input:   "<<-\"END\"\n\t\tabc\n\t  def\n\tEND\n",
+			Options: shellHeredocs,
+			Heredoc: Heredoc{Delimiter: "END", Quoted: true, Indent: IndentTabs, Body: "abc\n  def\n"},
+			Read:    "<<-\"END\"\n\t\tabc\n\t  def\n\tEND\n",
+		},
+		{
+			Input:   "<<~EOS\n    abc\n\n      def\n  EOS\n",
+			Options: rubyHeredocs,
+			Heredoc: Heredoc{Delimiter: "EOS", Indent: IndentCommon, Body: "abc\n\n  def\n"},
+			Read:    "<<~EOS\n    abc\n\n      def\n  EOS\n",
+		},
+		{
+			Input:   "<<EOF\nEOF",
+			Options: shellHeredocs,
+			Heredoc: Heredoc{Delimiter: "EOF"},
+			Read:    "<<EOF\nEOF",
+		},
+		{
+			Input:   "< <EOF",
+			Options: shellHeredocs,
+			Err:     ErrNotHeredoc,
+		},
+		{
+			Input:   "<< EOF",
+			Options: shellHeredocs,
+			Err:     ErrMissingDelimiter,
+			Offset:  2,
+		},
+		{
+			Input:   "<<'EOF\nabc",
+			Options: shellHeredocs,
+			Err:     ErrMissingDelimiter,
+			Offset:  6,
+		},
+		{
+			Input:   "<<EOF\nabc\n\tEOF\n",
+			Options: shellHeredocs,
+			Err:     ErrUnterminatedString,
+			Offset:  15,
+		},
+	} {
+		tk := NewStringTokeniser(test.Input)
+
+		h, err := tk.AcceptHeredoc(test.Options)
+		if err == nil {
+			if tk.Accept("\n") {
+				h, err = tk.AcceptHeredocBody()
+			} else {
+				t.Errorf("test %d: expecting newline after heredoc introducer", n+1)
+
+				continue
+			}
+		}
+
+		var se *SyntaxError
+
+		if !errors.Is(err, test.Err) {
+			t.Errorf("test %d: expecting error %v, got %v", n+1, test.Err, err)
+		} else if err != nil {
+			if !errors.As(err, &se) {
+				t.Errorf("test %d: expecting SyntaxError, got %T", n+1, err)
+			} else if se.Offset != test.Offset {
+				t.Errorf("test %d: expecting offset %d, got %d", n+1, test.Offset, se.Offset)
+			}
+		} else if h != test.Heredoc {
+			t.Errorf("test %d: expecting %#v, got %#v", n+1, test.Heredoc, h)
+		} else if read := tk.Get(); read != test.Read {
+			t.Errorf("test %d: expecting to read %q, got %q", n+1, test.Read, read)
+		} else if tk.PendingHeredocs() != 0 {
+			t.Errorf("test %d: expecting no pending heredocs, got %d", n+1, tk.PendingHeredocs())
+		}
+	}
+}
+
+func TestAcceptHeredocBodyNone(t *testing.T) {
+	tk := NewStringTokeniser("abc")
+
+	if _, err := tk.AcceptHeredocBody(); !errors.Is(err, ErrNoHeredoc) {
+		t.Errorf("expecting error %v, got %v", ErrNoHeredoc, err)
+	}
+}
+
+func TestAcceptUntil(t *testing.T) {
+	for n, test := range [...]struct {
+		Input    string
+		Delim    string
+		LineOnly bool
+		Data     string
+		Read     string
+		Err      error
+		Offset   int
+	}{
+		{
+			Input: "SELECT $x; $$ $x$ rest",
+			Delim: "$x$",
+			Data:  "SELECT $x; $$ ",
+			Read:  "SELECT $x; $$ $x$",
+		},
+		{
+			Input: "abc]]",
+			Delim: "]]",
+			Data:  "abc",
+			Read:  "abc]]",
+		},
+		{
+			Input:    "a END\nEND b\nEND\nrest",
+			Delim:    "END",
+			LineOnly: true,
+			Data:     "a END\nEND b\n",
+			Read:     "a END\nEND b\nEND\n",
+		},
+		{
+			Input:  "abc $x",
+			Delim:  "$x$",
+			Err:    ErrUnterminatedString,
+			Offset: 6,
+		},
+		{
+			Input:    "abc\n END",
+			Delim:    "END",
+			LineOnly: true,
+			Err:      ErrUnterminatedString,
+			Offset:   8,
+		},
+	} {
+		tk := NewStringTokeniser(test.Input)
+
+		data, err := tk.AcceptUntil(test.Delim, test.LineOnly)
+
+		var se *SyntaxError
+
+		if !errors.Is(err, test.Err) {
+			t.Errorf("test %d: expecting error %v, got %v", n+1, test.Err, err)
+		} else if err != nil {
+			if !errors.As(err, &se) {
+				t.Errorf("test %d: expecting SyntaxError, got %T", n+1, err)
+			} else if se.Offset != test.Offset {
+				t.Errorf("test %d: expecting offset %d, got %d", n+1, test.Offset, se.Offset)
+			}
+		} else if data != test.Data {
+			t.Errorf("test %d: expecting data %q, got %q", n+1, test.Data, data)
+		} else if read := tk.Get(); read != test.Read {
+			t.Errorf("test %d: expecting to read %q, got %q", n+1, test.Read, read)
+		}
+	}
+}
+
+func heredocTokeniser(t *Tokeniser) (Token, TokenFunc) {
+	switch {
+	case t.Peek() == -1:
+		return t.Done()
+	case t.Accept(" "):
+		t.AcceptRun(" ")
+		t.Get()
+
+		return t.Continue(heredocTokeniser)
+	case t.Accept("\n"):
+		t.Emit(1)
+
+		for t.PendingHeredocs() > 0 {
+			if _, err := t.AcceptHeredocBody(); err != nil {
+				t.Err = err
+
+				return t.Error()
+			}
+
+			t.Emit(3)
+		}
+
+		return t.Continue(heredocTokeniser)
+	case t.Peek() == '<':
+		if _, err := t.AcceptHeredoc(shellHeredocs); err != nil {
+			t.Err = err
+
+			return t.Error()
+		}
+
+		return t.Return(2, heredocTokeniser)
+	}
+
+	t.ExceptRun(" \n<")
+
+	return t.Return(0, heredocTokeniser)
+}
+
+func TestTokeniserHeredocs(t *testing.T) {
+	expected := []Token{
+		{Type: 0, Data: "cat"},
+		{Type: 2, Data: "<<A"},
+		{Type: 2, Data: "<<-'B'"},
+		{Type: 0, Data: "x"},
+		{Type: 1, Data: "\n"},
+		{Type: 3, Data: "a\nB\nA\n"},
+		{Type: 3, Data: "\tb\n\tB\n"},
+		{Type: 0, Data: "echo"},
+		{Type: 1, Data: "\n"},
+		{Type: TokenDone},
+	}
+
+	for n, p := range tokenisers("cat <<A <<-'B' x\na\nB\nA\n\tb\n\tB\necho\n") {
+		p.TokeniserState(heredocTokeniser)
+
+		var tokens []Token
+
+		for tk := range p.Iter {
+			tokens = append(tokens, tk)
+		}
+
+		if !reflect.DeepEqual(tokens, expected) {
+			t.Errorf("test (%s): expecting tokens %v, got %v", n, expected, tokens)
+		}
+	}
+}
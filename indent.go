@@ -0,0 +1,257 @@
+package parser
+
+import (
+	"slices"
+	"strings"
+)
+
+// Indenter tracks the indentation of lines, for indentation-sensitive
+// formats, such as Python and YAML, generating INDENT, DEDENT and NEWLINE
+// Tokens around the Tokens of a wrapped TokenFunc.
+//
+// An INDENT Token is generated at the start of a line that is indented more
+// than the previous line, and a DEDENT Token is generated for each level of
+// indentation closed by a line that is indented less. A NEWLINE Token is
+// generated at the end of each line, and at the end of the input if the last
+// line is not terminated.
+//
+// Blank lines, lines containing only comments, and line breaks within
+// brackets, do not generate any INDENT, DEDENT or NEWLINE Tokens.
+//
+// The INDENT and DEDENT Tokens have no Data, and end at the start of the first
+// Token of the line; the NEWLINE Token contains the newline, if any. The
+// indentation, spaces and tabs between Tokens, blank lines and line breaks
+// within brackets are discarded, unless PreserveTrivia is true.
+type Indenter struct {
+	// Indent, Dedent and Newline are the TokenTypes of the generated Tokens.
+	Indent, Dedent, Newline TokenType
+
+	// Trivia is the TokenType of the Tokens generated for otherwise discarded
+	// text when PreserveTrivia is true.
+	Trivia TokenType
+
+	// PreserveTrivia determines whether Trivia Tokens are generated, so that
+	// the Data of the generated Tokens reproduces the input.
+	PreserveTrivia bool
+
+	// Open and Close contain the TokenTypes of opening and closing brackets.
+	Open, Close []TokenType
+
+	// Comments contains the TokenTypes of comments.
+	Comments []TokenType
+
+	// TabWidth, when greater than zero, is the width of the tab stops that a
+	// tab moves the indentation to. When zero, the indentation of a line
+	// must match, or extend, the indentation of the enclosing level exactly,
+	// so that tabs and spaces cannot be used interchangeably.
+	TabWidth int
+
+	fn      TokenFunc
+	levels  []string
+	depth   int
+	started bool
+	blank   bool
+}
+
+// Wrap returns a TokenFunc that calls the given TokenFunc to generate the
+// Tokens of each line, adding the generated indentation Tokens.
+//
+// Spaces and tabs between Tokens are discarded, or generated as Trivia Tokens,
+// before the given TokenFunc is called, and the given TokenFunc must not read
+// newlines.
+func (i *Indenter) Wrap(fn TokenFunc) TokenFunc {
+	i.fn = fn
+	i.levels = append(i.levels[:0], "")
+	i.depth = 0
+	i.started = false
+	i.blank = true
+
+	return i.tokenise
+}
+
+func (i *Indenter) tokenise(t *Tokeniser) (Token, TokenFunc) {
+	t.AcceptRun(" \t")
+
+	r := t.Peek()
+
+	if !i.started && i.depth == 0 && r != '\n' && r != -1 {
+		return i.lineStart(t)
+	}
+
+	i.trivia(t)
+
+	switch r {
+	case '\n':
+		t.Next()
+
+		i.started = false
+
+		if i.depth > 0 || i.blank {
+			i.trivia(t)
+
+			return t.Continue(i.tokenise)
+		}
+
+		i.blank = true
+
+		return t.Return(i.Newline, i.tokenise)
+	case -1:
+		if !i.blank {
+			i.blank = true
+
+			return Token{Type: i.Newline}, i.tokenise
+		}
+
+		i.dedent(t, 0, len(t.queue), t.offset)
+	}
+
+	i.started = true
+
+	tk, next := i.fn(t)
+
+	return i.token(tk, next)
+}
+
+// trivia discards the data read since the last call to Get, or queues it as a
+// Trivia Token when PreserveTrivia is true.
+func (i *Indenter) trivia(t *Tokeniser) {
+	if !i.PreserveTrivia {
+		t.Get()
+	} else if t.Len() > 0 {
+		t.Emit(i.Trivia)
+	}
+}
+
+// lineStart calls the wrapped TokenFunc until it generates the first Token of
+// a line, which determines whether the line is a comment, and then places any
+// INDENT or DEDENT Tokens in the queue ahead of any Tokens generated by the
+// TokenFunc, ending at the offset of the first Token.
+func (i *Indenter) lineStart(t *Tokeniser) (Token, TokenFunc) {
+	indent := t.Get()
+
+	if i.PreserveTrivia && indent != "" {
+		t.EmitToken(Token{Type: i.Trivia, Data: indent})
+	}
+
+	offset := t.offset
+	at := len(t.queue)
+
+	i.started = true
+
+	tk, next := i.fn(t)
+	for tk.Type == tokenContinue && len(t.queue) == at {
+		tk, next = next(t)
+	}
+
+	first := tk
+	if len(t.queue) > at {
+		first = t.queue[at].token
+	}
+
+	if first.Type < 0 || slices.Contains(i.Comments, first.Type) {
+		return i.token(tk, next)
+	}
+
+	i.blank = false
+
+	switch top := i.levels[len(i.levels)-1]; i.compare(top, indent) {
+	case 1:
+		i.levels = append(i.levels, indent)
+		t.queue = slices.Insert(t.queue, at, queued{token: Token{Type: i.Indent}, end: offset})
+
+		return i.token(tk, next)
+	case -1:
+		n := len(i.levels) - 1
+
+		for n > 0 && i.compare(i.levels[n], indent) < 0 {
+			n--
+		}
+
+		if i.compare(i.levels[n], indent) != 0 {
+			return t.ReturnError(&SyntaxError{Err: ErrInconsistentDedent, Offset: offset})
+		}
+
+		i.dedent(t, n, at, offset)
+
+		return i.token(tk, next)
+	case 0:
+		return i.token(tk, next)
+	}
+
+	return t.ReturnError(&SyntaxError{Err: ErrInconsistentIndent, Offset: offset})
+}
+
+func (i *Indenter) dedent(t *Tokeniser, level, at, offset int) {
+	for range i.levels[level+1:] {
+		t.queue = slices.Insert(t.queue, at, queued{token: Token{Type: i.Dedent}, end: offset})
+	}
+
+	i.levels = i.levels[:level+1]
+}
+
+func (i *Indenter) token(tk Token, next TokenFunc) (Token, TokenFunc) {
+	switch tk.Type {
+	case TokenDone, TokenError:
+		return tk, next
+	case tokenContinue:
+		i.fn = next
+
+		return tk, i.tokenise
+	}
+
+	i.fn = next
+
+	if !slices.Contains(i.Comments, tk.Type) {
+		i.blank = false
+	}
+
+	if slices.Contains(i.Open, tk.Type) {
+		i.depth++
+	} else if slices.Contains(i.Close, tk.Type) && i.depth > 0 {
+		i.depth--
+	}
+
+	return tk, i.tokenise
+}
+
+// compare returns 1 if b is indented more than a, -1 if it is indented less,
+// 0 if they are equal, and 2 if they are inconsistent.
+func (i *Indenter) compare(a, b string) int {
+	if i.TabWidth > 0 {
+		wa, wb := i.width(a), i.width(b)
+
+		switch {
+		case wb > wa:
+			return 1
+		case wb < wa:
+			return -1
+		}
+
+		return 0
+	}
+
+	switch {
+	case a == b:
+		return 0
+	case strings.HasPrefix(b, a):
+		return 1
+	case strings.HasPrefix(a, b):
+		return -1
+	}
+
+	return 2
+}
+
+func (i *Indenter) width(indent string) int {
+	var w int
+
+	for _, c := range indent {
+		if c == '\t' {
+			w += i.TabWidth - w%i.TabWidth
+		} else {
+			w++
+		}
+	}
+
+	return w
+}
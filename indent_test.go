@@ -0,0 +1,304 @@
+package parser
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const (
+	indentIndent TokenType = 100 + iota
+	indentDedent
+	indentNewline
+)
+
+func newIndenter(tabWidth int) *Indenter {
+	return &Indenter{
+		Indent:   indentIndent,
+		Dedent:   indentDedent,
+		Newline:  indentNewline,
+		Open:     []TokenType{fixtureOpen},
+		Close:    []TokenType{fixtureClose},
+		Comments: []TokenType{fixtureComment},
+		TabWidth: tabWidth,
+	}
+}
+
+func TestIndenter(t *testing.T) {
+	var (
+		indent  = Token{Type: indentIndent}
+		dedent  = Token{Type: indentDedent}
+		newline = Token{Type: indentNewline, Data: "\n"}
+		comma   = Token{Type: fixtureComma, Data: ","}
+		eof     = Token{Type: indentNewline}
+		done    = Token{Type: TokenDone}
+	)
+
+	word := func(w string) Token {
+		return Token{Type: fixtureWord, Data: w}
+	}
+
+	for n, test := range [...]struct {
+		Input    string
+		TabWidth int
+		Tokens   []Token
+		Err      error
+	}{
+		{
+			Input:  "a b\nc",
+			Tokens: []Token{word("a"), word("b"), newline, word("c"), eof, done},
+		},
+		{
+			Input: "if:\n  a\n  b\nc\n",
+			Tokens: []Token{
+				word("if:"), newline,
+				indent, word("a"), newline,
+				word("b"), newline,
+				dedent, word("c"), newline,
+				done,
+			},
+		},
+		{
+			Input: "a:\n b:\n  c:\n   d\ne",
+			Tokens: []Token{
+				word("a:"), newline,
+				indent, word("b:"), newline,
+				indent, word("c:"), newline,
+				indent, word("d"), newline,
+				dedent, dedent, dedent, word("e"), eof,
+				done,
+			},
+		},
+		{
+			Input: "a:\n  b\n  c:\n    d",
+			Tokens: []Token{
+				word("a:"), newline,
+				indent, word("b"), newline,
+				word("c:"), newline,
+				indent, word("d"), eof,
+				dedent, dedent,
+				done,
+			},
+		},
+		{
+			Input: "a:\n\n   \n  # comment\n    # deeper comment\n  b # trailing\n",
+			Tokens: []Token{
+				word("a:"), newline,
+				{Type: fixtureComment, Data: "# comment"},
+				{Type: fixtureComment, Data: "# deeper comment"},
+				indent, word("b"), {Type: fixtureComment, Data: "# trailing"}, newline,
+				dedent,
+				done,
+			},
+		},
+		{
+			Input: "f(a,\n      b,\n c)\nd",
+			Tokens: []Token{
+				word("f"), {Type: fixtureOpen, Data: "("}, word("a"), comma, word("b"), comma, word("c"), {Type: fixtureClose, Data: ")"}, newline,
+				word("d"), eof,
+				done,
+			},
+		},
+		{
+			Input:    "a:\n\tb\n        c\n",
+			TabWidth: 8,
+			Tokens: []Token{
+				word("a:"), newline,
+				indent, word("b"), newline,
+				word("c"), newline,
+				dedent,
+				done,
+			},
+		},
+		{
+			Input:  "a:\n\tb\n        c\n",
+			Tokens: []Token{word("a:"), newline, indent, word("b"), newline},
+			Err:    ErrInconsistentIndent,
+		},
+		{
+			Input:  "a:\n    b\n  c\n",
+			Tokens: []Token{word("a:"), newline, indent, word("b"), newline},
+			Err:    ErrInconsistentDedent,
+		},
+	} {
+		p := NewStringTokeniser(test.Input)
+
+		p.TokeniserState(newIndenter(test.TabWidth).Wrap(fixtureTokeniser))
+
+		var tokens []Token
+
+		for tk := range p.Iter {
+			if tk.Type == TokenError {
+				break
+			}
+
+			tokens = append(tokens, tk)
+		}
+
+		if !errors.Is(p.Err, test.Err) && !(test.Err == nil && errors.Is(p.Err, io.EOF)) {
+			t.Errorf("test %d: expecting error %v, got %v", n+1, test.Err, p.Err)
+		} else if !reflect.DeepEqual(tokens, test.Tokens) {
+			t.Errorf("test %d: expecting tokens %v, got %v", n+1, test.Tokens, tokens)
+		}
+	}
+}
+
+func TestIndenterSpans(t *testing.T) {
+	p := New(NewStringTokeniser("a:\n  b\n    c\nd"))
+
+	p.TokeniserState(newIndenter(0).Wrap(fixtureTokeniser))
+
+	for n, expected := range [...]struct {
+		Type TokenType
+		Span Span
+	}{
+		{fixtureWord, Span{Start: 0, End: 2}},
+		{indentNewline, Span{Start: 2, End: 3}},
+		{indentIndent, Span{Start: 5, End: 5}},
+		{fixtureWord, Span{Start: 5, End: 6}},
+		{indentNewline, Span{Start: 6, End: 7}},
+		{indentIndent, Span{Start: 11, End: 11}},
+		{fixtureWord, Span{Start: 11, End: 12}},
+		{indentNewline, Span{Start: 12, End: 13}},
+		{indentDedent, Span{Start: 13, End: 13}},
+		{indentDedent, Span{Start: 13, End: 13}},
+		{fixtureWord, Span{Start: 13, End: 14}},
+		{indentNewline, Span{Start: 14, End: 14}},
+	} {
+		start := p.Offset()
+
+		if tk := p.Next(); tk.Type != expected.Type {
+			t.Errorf("test %d: expecting token type %d, got %d", n+1, expected.Type, tk.Type)
+		} else if span := (Span{Start: start, End: p.End()}); span != expected.Span {
+			t.Errorf("test %d: expecting span %v, got %v", n+1, expected.Span, span)
+		}
+	}
+}
+
+func TestIndenterTrivia(t *testing.T) {
+	var (
+		indent  = Token{Type: indentIndent}
+		dedent  = Token{Type: indentDedent}
+		newline = Token{Type: indentNewline, Data: "\n"}
+		comma   = Token{Type: fixtureComma, Data: ","}
+		done    = Token{Type: TokenDone}
+	)
+
+	word := func(w string) Token {
+		return Token{Type: fixtureWord, Data: w}
+	}
+
+	trivia := func(w string) Token {
+		return Token{Type: fixtureWhitespace, Data: w}
+	}
+
+	for n, test := range [...]struct {
+		Input  string
+		Tokens []Token
+	}{
+		{
+			Input: "a:\n\n   \n  # comment\n  b  c \n",
+			Tokens: []Token{
+				word("a:"), newline,
+				trivia("\n"),
+				trivia("   "), trivia("\n"),
+				trivia("  "), {Type: fixtureComment, Data: "# comment"}, trivia("\n"),
+				trivia("  "), indent, word("b"), trivia("  "), word("c"), trivia(" "), newline,
+				dedent,
+				done,
+			},
+		},
+		{
+			Input: "f(a,\n    b)\n  c\nd",
+			Tokens: []Token{
+				word("f"), {Type: fixtureOpen, Data: "("}, word("a"), comma, trivia("\n"),
+				trivia("    "), word("b"), {Type: fixtureClose, Data: ")"}, newline,
+				trivia("  "), indent, word("c"), newline,
+				dedent, word("d"), {Type: indentNewline},
+				done,
+			},
+		},
+	} {
+		i := newIndenter(0)
+		i.Trivia = fixtureWhitespace
+		i.PreserveTrivia = true
+
+		p := NewStringTokeniser(test.Input)
+
+		p.TokeniserState(i.Wrap(fixtureTokeniser))
+
+		var (
+			tokens []Token
+			data   strings.Builder
+		)
+
+		for tk := range p.Iter {
+			tokens = append(tokens, tk)
+
+			data.WriteString(tk.Data)
+		}
+
+		if !reflect.DeepEqual(tokens, test.Tokens) {
+			t.Errorf("test %d: expecting tokens %v, got %v", n+1, test.Tokens, tokens)
+		} else if data.String() != test.Input {
+			t.Errorf("test %d: expecting data %q, got %q", n+1, test.Input, data.String())
+		}
+	}
+}
+
+func indentContinue(t *Tokeniser) (Token, TokenFunc) {
+	if t.Accept("@") {
+		t.Emit(fixtureWord)
+
+		return t.Continue(indentContinue)
+	} else if t.Accept("%") {
+		t.Get()
+
+		return t.Continue(indentContinue)
+	}
+
+	tk, next := fixtureTokeniser(t)
+	if tk.Type < 0 {
+		return tk, next
+	}
+
+	return tk, indentContinue
+}
+
+func TestIndenterContinue(t *testing.T) {
+	var (
+		indent  = Token{Type: indentIndent}
+		dedent  = Token{Type: indentDedent}
+		newline = Token{Type: indentNewline, Data: "\n"}
+		done    = Token{Type: TokenDone}
+	)
+
+	word := func(w string) Token {
+		return Token{Type: fixtureWord, Data: w}
+	}
+
+	p := NewStringTokeniser("a:\n  @b\n  %d\n%c\n@@e\n")
+
+	p.TokeniserState(newIndenter(0).Wrap(indentContinue))
+
+	var tokens []Token
+
+	for tk := range p.Iter {
+		tokens = append(tokens, tk)
+	}
+
+	expected := []Token{
+		word("a:"), newline,
+		indent, word("@"), word("b"), newline,
+		word("d"), newline,
+		dedent, word("c"), newline,
+		word("@"), word("@"), word("e"), newline,
+		done,
+	}
+
+	if !reflect.DeepEqual(tokens, expected) {
+		t.Errorf("expecting tokens %v, got %v", expected, tokens)
+	}
+}
@@ -0,0 +1,58 @@
+package parser
+
+import "strconv"
+
+// Names maps TokenTypes and PhraseTypes to human readable names, for use in
+// error messages and debugging output.
+type Names struct {
+	Tokens  map[TokenType]string
+	Phrases map[PhraseType]string
+}
+
+// Token returns the name registered for the given TokenType.
+//
+// TokenDone and TokenError have default names, and any other unregistered
+// type is named by its numeric value.
+//
+// Token can be called on a nil *Names.
+func (n *Names) Token(typ TokenType) string {
+	if n != nil {
+		if name, ok := n.Tokens[typ]; ok {
+			return name
+		}
+	}
+
+	switch typ {
+	case TokenDone:
+		return "TokenDone"
+	case TokenError:
+		return "TokenError"
+	}
+
+	return "TokenType(" + strconv.Itoa(int(typ)) + ")"
+}
+
+// Phrase returns the name registered for the given PhraseType.
+//
+// PhraseDone, PhraseError and PhraseSkipped have default names, and any other unregistered
+// type is named by its numeric value.
+//
+// Phrase can be called on a nil *Names.
+func (n *Names) Phrase(typ PhraseType) string {
+	if n != nil {
+		if name, ok := n.Phrases[typ]; ok {
+			return name
+		}
+	}
+
+	switch typ {
+	case PhraseDone:
+		return "PhraseDone"
+	case PhraseError:
+		return "PhraseError"
+	case PhraseSkipped:
+		return "PhraseSkipped"
+	}
+
+	return "PhraseType(" + strconv.Itoa(int(typ)) + ")"
+}
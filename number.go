@@ -0,0 +1,388 @@
+package parser
+
+import (
+	"errors"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// NumberOptions determines which forms of number literal are accepted by
+// Tokeniser.AcceptNumber.
+type NumberOptions struct {
+	// Hex, Octal and Binary enable integers with the prefixes 0x, 0o and 0b,
+	// respectively, in either case.
+	Hex, Octal, Binary bool
+
+	// Separator, if not zero, is a rune, such as '_', that may appear between
+	// digits, and after a base prefix.
+	Separator rune
+
+	// Fraction enables decimal fractions, such as 1.5, .5 and 1.
+	Fraction bool
+
+	// Exponent enables decimal exponents, such as 1e10 and 1.5E-3.
+	Exponent bool
+
+	// HexFloat enables hexadecimal floating-point numbers, such as 0x1.8p3,
+	// which require a binary exponent. Requires Hex.
+	HexFloat bool
+
+	// Suffixes contains the type suffixes, such as "u", "L" or "f32", that may
+	// follow a number. The longest matching suffix is accepted.
+	Suffixes []string
+}
+
+// Number is a number literal read by Tokeniser.AcceptNumber.
+type Number struct {
+	// Base is the base of the number; 2, 8, 10 or 16.
+	Base int
+
+	// Float is true when the number has a fraction or exponent.
+	Float bool
+
+	// Suffix is the type suffix of the number, if any.
+	Suffix string
+
+	value string
+}
+
+// SyntaxError is returned by the literal scanning methods of Tokeniser, and
+// gives the offset, within the input, at which a malformed literal was
+// detected.
+type SyntaxError struct {
+	Err    error
+	Offset int
+}
+
+// Error implements the error interface.
+func (s *SyntaxError) Error() string {
+	return s.Err.Error() + " at offset " + strconv.Itoa(s.Offset)
+}
+
+// Unwrap returns the underlying error.
+func (s *SyntaxError) Unwrap() error {
+	return s.Err
+}
+
+func (t *Tokeniser) syntaxError(err error) error {
+	return &SyntaxError{Err: err, Offset: t.offset + t.Len()}
+}
+
+// AcceptNumber reads a number literal, in one of the forms enabled by the
+// given options, returning its value.
+//
+// If the next character cannot start a number, no characters are read and
+// an error wrapping ErrNotNumber is returned. Otherwise, when the literal is
+// malformed, the characters up to the point where the problem was detected
+// are read, and a *SyntaxError, giving the offset of the problem, is returned.
+func (t *Tokeniser) AcceptNumber(opts NumberOptions) (Number, error) {
+	n := Number{Base: 10}
+
+	var (
+		sb     strings.Builder
+		digits int
+		err    error
+	)
+
+	switch r := t.Peek(); {
+	case r == '0':
+		t.Next()
+
+		if base := numberPrefix(t.Peek(), opts); base != 0 {
+			t.Next()
+
+			n.Base = base
+
+			if digits, err = t.acceptDigits(&sb, base, opts.Separator, true); err != nil {
+				return n, err
+			} else if digits == 0 && !(base == 16 && opts.HexFloat && t.Peek() == '.') {
+				return n, t.syntaxError(ErrMissingDigits)
+			}
+		} else {
+			sb.WriteByte('0')
+
+			if digits, err = t.acceptDigits(&sb, 10, opts.Separator, true); err != nil {
+				return n, err
+			}
+
+			digits++
+		}
+	case r >= '1' && r <= '9':
+		if digits, err = t.acceptDigits(&sb, 10, opts.Separator, false); err != nil {
+			return n, err
+		}
+	case r == '.' && opts.Fraction:
+		s := t.State()
+
+		t.Next()
+
+		if !isDigit(t.Peek(), 10) {
+			s.Reset()
+
+			return n, t.syntaxError(ErrNotNumber)
+		}
+
+		s.Reset()
+	default:
+		return n, t.syntaxError(ErrNotNumber)
+	}
+
+	switch n.Base {
+	case 10:
+		err = t.acceptDecimalFloat(&n, &sb, opts)
+	case 16:
+		err = t.acceptHexFloat(&n, &sb, digits, opts)
+	}
+
+	if err != nil {
+		return n, err
+	}
+
+	n.Suffix = t.AcceptWord(opts.Suffixes, false)
+
+	if r := t.Peek(); isDigit(r, 10) {
+		return n, t.syntaxError(ErrInvalidDigit)
+	} else if r == '_' || r == opts.Separator || unicode.IsLetter(r) || unicode.IsDigit(r) {
+		return n, t.syntaxError(ErrInvalidSuffix)
+	}
+
+	n.value = sb.String()
+
+	return n, nil
+}
+
+func numberPrefix(r rune, opts NumberOptions) int {
+	switch r {
+	case 'x', 'X':
+		if opts.Hex {
+			return 16
+		}
+	case 'o', 'O':
+		if opts.Octal {
+			return 8
+		}
+	case 'b', 'B':
+		if opts.Binary {
+			return 2
+		}
+	}
+
+	return 0
+}
+
+func isDigit(r rune, base int) bool {
+	switch {
+	case r >= '0' && r <= '9':
+		return int(r-'0') < base
+	case r >= 'a' && r <= 'f':
+		return base == 16
+	case r >= 'A' && r <= 'F':
+		return base == 16
+	}
+
+	return false
+}
+
+func (t *Tokeniser) acceptDigits(sb *strings.Builder, base int, sep rune, leadingSep bool) (int, error) {
+	var (
+		digits int
+		sepErr error
+	)
+
+	for {
+		r := t.Peek()
+
+		switch {
+		case isDigit(r, base):
+			sb.WriteRune(t.Next())
+
+			digits++
+			sepErr = nil
+		case sep != 0 && r == sep:
+			if sepErr != nil || digits == 0 && !leadingSep {
+				return digits, t.syntaxError(ErrInvalidSeparator)
+			}
+
+			sepErr = t.syntaxError(ErrInvalidSeparator)
+
+			t.Next()
+		default:
+			return digits, sepErr
+		}
+	}
+}
+
+func (t *Tokeniser) acceptDecimalFloat(n *Number, sb *strings.Builder, opts NumberOptions) error {
+	if opts.Fraction && t.AcceptRune('.') {
+		n.Float = true
+
+		sb.WriteByte('.')
+
+		if _, err := t.acceptDigits(sb, 10, opts.Separator, false); err != nil {
+			return err
+		}
+	}
+
+	if opts.Exponent && t.Accept("eE") {
+		n.Float = true
+
+		sb.WriteByte('e')
+
+		return t.acceptExponent(sb, opts.Separator)
+	}
+
+	return nil
+}
+
+func (t *Tokeniser) acceptHexFloat(n *Number, sb *strings.Builder, digits int, opts NumberOptions) error {
+	if !opts.HexFloat {
+		return nil
+	}
+
+	if t.AcceptRune('.') {
+		n.Float = true
+
+		sb.WriteByte('.')
+
+		frac, err := t.acceptDigits(sb, 16, opts.Separator, false)
+		if err != nil {
+			return err
+		} else if digits+frac == 0 {
+			return t.syntaxError(ErrMissingDigits)
+		}
+	}
+
+	if t.Accept("pP") {
+		value := sb.String()
+
+		n.Float = true
+
+		sb.Reset()
+		sb.WriteString("0x")
+		sb.WriteString(value)
+		sb.WriteByte('p')
+
+		return t.acceptExponent(sb, opts.Separator)
+	} else if n.Float {
+		return t.syntaxError(ErrMissingExponent)
+	}
+
+	return nil
+}
+
+func (t *Tokeniser) acceptExponent(sb *strings.Builder, sep rune) error {
+	if r := t.Peek(); r == '+' || r == '-' {
+		sb.WriteRune(t.Next())
+	}
+
+	digits, err := t.acceptDigits(sb, 10, sep, false)
+	if err != nil {
+		return err
+	} else if digits == 0 {
+		return t.syntaxError(ErrMissingExponent)
+	}
+
+	return nil
+}
+
+// Int64 returns the value of the number as an int64.
+func (n Number) Int64() (int64, error) {
+	if n.Float {
+		return 0, ErrNotInteger
+	}
+
+	i, err := strconv.ParseInt(n.value, n.Base, 64)
+	if errors.Is(err, strconv.ErrRange) {
+		return i, ErrOverflow
+	}
+
+	return i, err
+}
+
+// Uint64 returns the value of the number as a uint64.
+func (n Number) Uint64() (uint64, error) {
+	if n.Float {
+		return 0, ErrNotInteger
+	}
+
+	u, err := strconv.ParseUint(n.value, n.Base, 64)
+	if errors.Is(err, strconv.ErrRange) {
+		return u, ErrOverflow
+	}
+
+	return u, err
+}
+
+// BigInt returns the value of the number as a *big.Int.
+func (n Number) BigInt() (*big.Int, error) {
+	if n.Float {
+		return nil, ErrNotInteger
+	}
+
+	i, ok := new(big.Int).SetString(n.value, n.Base)
+	if !ok {
+		return nil, ErrNotNumber
+	}
+
+	return i, nil
+}
+
+// Float64 returns the value of the number as a float64, rounded to the
+// nearest representable value.
+//
+// Returns ErrOverflow if the value is too large to be represented.
+func (n Number) Float64() (float64, error) {
+	if !n.Float {
+		i, err := n.BigInt()
+		if err != nil {
+			return 0, err
+		}
+
+		if f, _ := new(big.Float).SetInt(i).Float64(); !math.IsInf(f, 0) {
+			return f, nil
+		}
+
+		return 0, ErrOverflow
+	}
+
+	f, err := strconv.ParseFloat(n.value, 64)
+	if err != nil && !errors.Is(err, strconv.ErrRange) {
+		return 0, err
+	} else if math.IsInf(f, 0) {
+		return 0, ErrOverflow
+	}
+
+	return f, nil
+}
+
+// BigFloat returns the value of the number as a *big.Float with the given
+// precision, in bits. If prec is zero, the precision is 64 for fractional
+// numbers, and exact for integers.
+//
+// Returns ErrOverflow if the exponent is outside of the range of a big.Float.
+func (n Number) BigFloat(prec uint) (*big.Float, error) {
+	if !n.Float {
+		i, err := n.BigInt()
+		if err != nil {
+			return nil, err
+		}
+
+		f := new(big.Float).SetInt(i)
+
+		if prec != 0 {
+			f.SetPrec(prec)
+		}
+
+		return f, nil
+	}
+
+	f, _, err := big.ParseFloat(n.value, 0, prec, big.ToNearestEven)
+	if err != nil {
+		return nil, ErrOverflow
+	}
+
+	return f, nil
+}
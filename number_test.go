@@ -0,0 +1,322 @@
+package parser
+
+import (
+	"errors"
+	"math"
+	"math/big"
+	"testing"
+)
+
+var allNumbers = NumberOptions{
+	Hex:       true,
+	Octal:     true,
+	Binary:    true,
+	Separator: '_',
+	Fraction:  true,
+	Exponent:  true,
+	HexFloat:  true,
+	Suffixes:  []string{"u", "ul", "f32"},
+}
+
+func TestAcceptNumber(t *testing.T) {
+	for n, test := range [...]struct {
+		Input   string
+		Options NumberOptions
+		Number  Number
+		Read    string
+		Err     error
+		Offset  int
+	}{
+		{
+			Input:   "123;",
+			Options: allNumbers,
+			Number:  Number{Base: 10, value: "123"},
+			Read:    "123",
+		},
+		{
+			Input:   "0",
+			Options: allNumbers,
+			Number:  Number{Base: 10, value: "0"},
+			Read:    "0",
+		},
+		{
+			Input:   "1_000_000",
+			Options: allNumbers,
+			Number:  Number{Base: 10, value: "1000000"},
+			Read:    "1_000_000",
+		},
+		{
+			Input:   "0xFF_ff",
+			Options: allNumbers,
+			Number:  Number{Base: 16, value: "FFff"},
+			Read:    "0xFF_ff",
+		},
+		{
+			Input:   "0o_17",
+			Options: allNumbers,
+			Number:  Number{Base: 8, value: "17"},
+			Read:    "0o_17",
+		},
+		{
+			Input:   "0B101",
+			Options: allNumbers,
+			Number:  Number{Base: 2, value: "101"},
+			Read:    "0B101",
+		},
+		{
+			Input:   "1.5e-3)",
+			Options: allNumbers,
+			Number:  Number{Base: 10, Float: true, value: "1.5e-3"},
+			Read:    "1.5e-3",
+		},
+		{
+			Input:   ".5",
+			Options: allNumbers,
+			Number:  Number{Base: 10, Float: true, value: ".5"},
+			Read:    ".5",
+		},
+		{
+			Input:   "1.",
+			Options: allNumbers,
+			Number:  Number{Base: 10, Float: true, value: "1."},
+			Read:    "1.",
+		},
+		{
+			Input:   "0x1.8p3",
+			Options: allNumbers,
+			Number:  Number{Base: 16, Float: true, value: "0x1.8p3"},
+			Read:    "0x1.8p3",
+		},
+		{
+			Input:   "0x.8P+1",
+			Options: allNumbers,
+			Number:  Number{Base: 16, Float: true, value: "0x.8p+1"},
+			Read:    "0x.8P+1",
+		},
+		{
+			Input:   "10ul",
+			Options: allNumbers,
+			Number:  Number{Base: 10, Suffix: "ul", value: "10"},
+			Read:    "10ul",
+		},
+		{
+			Input:   "1.5f32",
+			Options: allNumbers,
+			Number:  Number{Base: 10, Float: true, Suffix: "f32", value: "1.5"},
+			Read:    "1.5f32",
+		},
+		{
+			Input:   "1.5",
+			Options: NumberOptions{},
+			Number:  Number{Base: 10, value: "1"},
+			Read:    "1",
+		},
+		{
+			Input:   "0x1",
+			Options: NumberOptions{},
+			Err:     ErrInvalidSuffix,
+			Offset:  1,
+		},
+		{
+			Input:   "a",
+			Options: allNumbers,
+			Err:     ErrNotNumber,
+		},
+		{
+			Input:   ".a",
+			Options: allNumbers,
+			Err:     ErrNotNumber,
+		},
+		{
+			Input:   "0x",
+			Options: allNumbers,
+			Err:     ErrMissingDigits,
+			Offset:  2,
+		},
+		{
+			Input:   "0b1021",
+			Options: allNumbers,
+			Err:     ErrInvalidDigit,
+			Offset:  4,
+		},
+		{
+			Input:   "0o78",
+			Options: allNumbers,
+			Err:     ErrInvalidDigit,
+			Offset:  3,
+		},
+		{
+			Input:   "1__0",
+			Options: allNumbers,
+			Err:     ErrInvalidSeparator,
+			Offset:  2,
+		},
+		{
+			Input:   "10_",
+			Options: allNumbers,
+			Err:     ErrInvalidSeparator,
+			Offset:  2,
+		},
+		{
+			Input:   "1_.5",
+			Options: allNumbers,
+			Err:     ErrInvalidSeparator,
+			Offset:  1,
+		},
+		{
+			Input:   "1e+",
+			Options: allNumbers,
+			Err:     ErrMissingExponent,
+			Offset:  3,
+		},
+		{
+			Input:   "0x1.8",
+			Options: allNumbers,
+			Err:     ErrMissingExponent,
+			Offset:  5,
+		},
+		{
+			Input:   "12ab",
+			Options: allNumbers,
+			Err:     ErrInvalidSuffix,
+			Offset:  2,
+		},
+	} {
+		for backend, tk := range tokenisers(test.Input) {
+			num, err := tk.AcceptNumber(test.Options)
+
+			var se *SyntaxError
+
+			if !errors.Is(err, test.Err) {
+				t.Errorf("test %d (%s): expecting error %v, got %v", n+1, backend, test.Err, err)
+			} else if err != nil {
+				if !errors.As(err, &se) {
+					t.Errorf("test %d (%s): expecting SyntaxError, got %T", n+1, backend, err)
+				} else if se.Offset != test.Offset {
+					t.Errorf("test %d (%s): expecting offset %d, got %d", n+1, backend, test.Offset, se.Offset)
+				}
+			} else if num.Base != test.Number.Base || num.Float != test.Number.Float || num.Suffix != test.Number.Suffix || num.value != test.Number.value {
+				t.Errorf("test %d (%s): expecting number %#v, got %#v", n+1, backend, test.Number, num)
+			} else if read := tk.Get(); read != test.Read {
+				t.Errorf("test %d (%s): expecting to read %q, got %q", n+1, backend, test.Read, read)
+			}
+		}
+	}
+}
+
+func TestNumberConversions(t *testing.T) {
+	for n, test := range [...]struct {
+		Input       string
+		Int64       int64
+		Int64Err    error
+		Uint64      uint64
+		Uint64Err   error
+		BigInt      string
+		BigIntErr   error
+		Float64     float64
+		Float64Err  error
+		BigFloat    string
+		BigFloatErr error
+	}{
+		{
+			Input:    "0xff",
+			Int64:    255,
+			Uint64:   255,
+			BigInt:   "255",
+			Float64:  255,
+			BigFloat: "255",
+		},
+		{
+			Input:    "18446744073709551615",
+			Int64:    math.MaxInt64,
+			Int64Err: ErrOverflow,
+			Uint64:   math.MaxUint64,
+			BigInt:   "18446744073709551615",
+			Float64:  18446744073709551615,
+			BigFloat: "18446744073709551615",
+		},
+		{
+			Input:     "0b1_0000000000000000000000000000000000000000000000000000000000000000",
+			Int64:     math.MaxInt64,
+			Int64Err:  ErrOverflow,
+			Uint64:    math.MaxUint64,
+			Uint64Err: ErrOverflow,
+			BigInt:    "18446744073709551616",
+			Float64:   18446744073709551616,
+			BigFloat:  "18446744073709551616",
+		},
+		{
+			Input:     "1.5e3",
+			Int64Err:  ErrNotInteger,
+			Uint64Err: ErrNotInteger,
+			BigIntErr: ErrNotInteger,
+			Float64:   1500,
+			BigFloat:  "1500",
+		},
+		{
+			Input:     "0x1.8p1",
+			Int64Err:  ErrNotInteger,
+			Uint64Err: ErrNotInteger,
+			BigIntErr: ErrNotInteger,
+			Float64:   3,
+			BigFloat:  "3",
+		},
+		{
+			Input:      "1e400",
+			Int64Err:   ErrNotInteger,
+			Uint64Err:  ErrNotInteger,
+			BigIntErr:  ErrNotInteger,
+			Float64Err: ErrOverflow,
+			BigFloat:   "1e+400",
+		},
+		{
+			Input:       "1e3000000000",
+			Int64Err:    ErrNotInteger,
+			Uint64Err:   ErrNotInteger,
+			BigIntErr:   ErrNotInteger,
+			Float64Err:  ErrOverflow,
+			BigFloatErr: ErrOverflow,
+		},
+	} {
+		tk := NewStringTokeniser(test.Input)
+
+		num, err := tk.AcceptNumber(allNumbers)
+		if err != nil {
+			t.Errorf("test %d: unexpected error: %s", n+1, err)
+
+			continue
+		}
+
+		if i, err := num.Int64(); !errors.Is(err, test.Int64Err) {
+			t.Errorf("test %d: expecting Int64 error %v, got %v", n+1, test.Int64Err, err)
+		} else if i != test.Int64 {
+			t.Errorf("test %d: expecting Int64 %d, got %d", n+1, test.Int64, i)
+		}
+
+		if u, err := num.Uint64(); !errors.Is(err, test.Uint64Err) {
+			t.Errorf("test %d: expecting Uint64 error %v, got %v", n+1, test.Uint64Err, err)
+		} else if u != test.Uint64 {
+			t.Errorf("test %d: expecting Uint64 %d, got %d", n+1, test.Uint64, u)
+		}
+
+		if b, err := num.BigInt(); !errors.Is(err, test.BigIntErr) {
+			t.Errorf("test %d: expecting BigInt error %v, got %v", n+1, test.BigIntErr, err)
+		} else if err == nil && b.String() != test.BigInt {
+			t.Errorf("test %d: expecting BigInt %s, got %s", n+1, test.BigInt, b)
+		}
+
+		if f, err := num.Float64(); !errors.Is(err, test.Float64Err) {
+			t.Errorf("test %d: expecting Float64 error %v, got %v", n+1, test.Float64Err, err)
+		} else if f != test.Float64 {
+			t.Errorf("test %d: expecting Float64 %v, got %v", n+1, test.Float64, f)
+		}
+
+		if b, err := num.BigFloat(0); !errors.Is(err, test.BigFloatErr) {
+			t.Errorf("test %d: expecting BigFloat error %v, got %v", n+1, test.BigFloatErr, err)
+		} else if err == nil {
+			if expected, _, _ := big.ParseFloat(test.BigFloat, 10, b.Prec(), big.ToNearestEven); b.Cmp(expected) != 0 {
+				t.Errorf("test %d: expecting BigFloat %s, got %s", n+1, test.BigFloat, b.Text('g', 20))
+			}
+		}
+	}
+}
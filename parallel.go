@@ -0,0 +1,192 @@
+package parser
+
+import (
+	"context"
+	"runtime"
+)
+
+// BoundaryFunc finds a safe place to split the input, at or after the given
+// offset, such as the start of a line outside of any string, returning the
+// offset of the boundary and a guess of the TokenFunc that the Tokeniser would
+// be in at that point.
+//
+// A negative offset indicates that there is no boundary.
+type BoundaryFunc func(data []byte, offset int) (int, TokenFunc)
+
+// ParallelOptions determines how the input of NewParallelTokeniser is split.
+type ParallelOptions struct {
+	// Chunks is the number of chunks to split the input into. When less than
+	// one, runtime.GOMAXPROCS(0) is used.
+	Chunks int
+
+	// Boundary is called to find the start of each chunk after the first.
+	//
+	// When nil, the input is not split.
+	Boundary BoundaryFunc
+
+	// Equal reports whether the TokenFunc that a Tokeniser is in at the start
+	// of a chunk is equivalent to the TokenFunc guessed by Boundary, including
+	// any state captured by closures.
+	//
+	// Equal must be set when Boundary is; otherwise, no guess could be
+	// checked, and the returned Tokeniser generates only a TokenError Token,
+	// with Err set to ErrNoEqual.
+	Equal func(actual, guess TokenFunc) bool
+}
+
+type chunk struct {
+	start, end int
+	guess      TokenFunc
+	t          *Tokeniser
+	tokens     []queued
+	final      bool
+	done       chan struct{}
+}
+
+// NewParallelTokeniser tokenises the given data, starting with the given
+// TokenFunc, by splitting it into chunks, at the boundaries found by
+// opts.Boundary, and running the TokenFuncs on each chunk concurrently.
+//
+// The returned Tokeniser returns the Tokens of each chunk, in order, with
+// offsets and spans relative to the start of the data, as would be generated
+// by tokenising the data in a single Tokeniser. The Tokens of a chunk are
+// returned as soon as it, and all of the chunks before it, have been
+// tokenised.
+//
+// The Tokens of a chunk are only used when the Tokens of the preceding chunk
+// end exactly at its boundary, with no Tokens or heredocs pending, and with a
+// TokenFunc that opts.Equal reports to be equivalent to the one guessed by
+// opts.Boundary. Otherwise, the preceding chunk is continued through the
+// chunk, and the next boundary is checked in the same way.
+//
+// As with Pipeline, the reading methods of the returned Tokeniser, such as
+// Accept and Get, must not be used, and StopPipeline, or stopping Iter early,
+// stops the tokenising of any remaining chunks.
+func NewParallelTokeniser(data []byte, fn TokenFunc, opts ParallelOptions) Tokeniser {
+	if opts.Boundary != nil && opts.Equal == nil {
+		return Tokeniser{
+			tokeniser: &strParser{},
+			Err:       ErrNoEqual,
+			state:     (*Tokeniser).Error,
+		}
+	}
+
+	chunks := splitChunks(data, fn, opts)
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := make(chan batch, len(chunks))
+
+	for _, c := range chunks {
+		go func() {
+			defer close(c.done)
+
+			c.tokens = c.lex(ctx, nil, c.end)
+		}()
+	}
+
+	go stitchChunks(ctx, ch, chunks, opts.Equal)
+
+	return Tokeniser{
+		tokeniser: &strParser{},
+		pipe: &pipeline{
+			ctx:    ctx,
+			cancel: cancel,
+			ch:     ch,
+		},
+	}
+}
+
+// stitchChunks sends the Tokens of each chunk, in order, as each is finished,
+// continuing the preceding chunk through any chunk whose Tokens cannot be
+// used.
+func stitchChunks(ctx context.Context, ch chan<- batch, chunks []*chunk, equal func(TokenFunc, TokenFunc) bool) {
+	var cur *chunk
+
+	for _, next := range chunks {
+		select {
+		case <-next.done:
+		case <-ctx.Done():
+			return
+		}
+
+		var tokens []queued
+
+		if cur == nil || cur.t.end == next.start && len(cur.t.queue) == 0 && len(cur.t.heredocs) == 0 && equal(cur.t.state, next.guess) {
+			cur = next
+			tokens = next.tokens
+		} else {
+			tokens = cur.lex(ctx, nil, next.end)
+		}
+
+		b := batch{tokens: tokens}
+
+		if cur.final {
+			b.err = cur.t.Err
+		}
+
+		select {
+		case ch <- b:
+		case <-ctx.Done():
+			return
+		}
+
+		if cur.final {
+			return
+		}
+	}
+}
+
+func splitChunks(data []byte, fn TokenFunc, opts ParallelOptions) []*chunk {
+	n := opts.Chunks
+	if n < 1 {
+		n = runtime.GOMAXPROCS(0)
+	}
+
+	chunks := []*chunk{{guess: fn}}
+
+	if opts.Boundary != nil {
+		size := len(data) / n
+
+		for i := 1; i < n; i++ {
+			offset, guess := opts.Boundary(data, max(i*size, chunks[len(chunks)-1].start+1))
+			if offset < 0 || offset >= len(data) {
+				break
+			} else if offset <= chunks[len(chunks)-1].start {
+				continue
+			}
+
+			chunks[len(chunks)-1].end = offset
+			chunks = append(chunks, &chunk{start: offset, guess: guess})
+		}
+	}
+
+	chunks[len(chunks)-1].end = len(data) + 1
+
+	for _, c := range chunks {
+		c.t = &Tokeniser{
+			tokeniser: &byteParser{data: data[c.start:]},
+			state:     c.guess,
+			offset:    c.start,
+			end:       c.start,
+		}
+		c.done = make(chan struct{})
+	}
+
+	return chunks
+}
+
+// lex appends Tokens to the given slice until the end of a Token reaches the
+// given offset with no Tokens pending, until a TokenDone or TokenError Token is
+// generated, or until the context is cancelled.
+func (c *chunk) lex(ctx context.Context, tokens []queued, end int) []queued {
+	for !c.final && ctx.Err() == nil {
+		if c.t.end >= end && len(c.t.queue) == 0 {
+			break
+		}
+
+		tk := c.t.get()
+		tokens = append(tokens, queued{token: tk, end: c.t.end})
+		c.final = tk.Type == TokenDone || tk.Type == TokenError
+	}
+
+	return tokens
+}
@@ -0,0 +1,275 @@
+package parser
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func parallelCode(t *Tokeniser) (Token, TokenFunc) {
+	switch {
+	case t.Peek() == -1:
+		return t.Done()
+	case t.Accept(" "):
+		t.AcceptRun(" ")
+
+		return t.Return(0, parallelCode)
+	case t.Accept("\n"):
+		return t.Return(1, parallelCode)
+	case t.Accept("\""):
+		if t.ExceptRun("\"") == -1 {
+			return t.ReturnError(ErrUnterminatedString)
+		}
+
+		t.Next()
+
+		return t.Return(2, parallelCode)
+	case t.AcceptString("%%\n", false) == 3:
+		return t.Return(3, parallelText)
+	}
+
+	t.ExceptRun(" \n\"")
+
+	return t.Return(4, parallelCode)
+}
+
+func parallelText(t *Tokeniser) (Token, TokenFunc) {
+	if t.Peek() == -1 {
+		return t.Done()
+	} else if t.AcceptString("%%\n", false) == 3 {
+		return t.Return(3, parallelCode)
+	}
+
+	t.ExceptRun("\n")
+	t.Accept("\n")
+
+	return t.Return(5, parallelText)
+}
+
+func parallelLines(data []byte, offset int) (int, TokenFunc) {
+	if n := bytes.IndexByte(data[min(offset, len(data)):], '\n'); n >= 0 {
+		return offset + n + 1, parallelCode
+	}
+
+	return -1, nil
+}
+
+// probeEqual compares TokenFuncs by the first Token they generate for the
+// given input.
+func probeEqual(probe string) func(TokenFunc, TokenFunc) bool {
+	return func(a, b TokenFunc) bool {
+		ta, tb := NewStringTokeniser(probe), NewStringTokeniser(probe)
+
+		ta.TokeniserState(a)
+		tb.TokeniserState(b)
+
+		return ta.get() == tb.get()
+	}
+}
+
+type spanToken struct {
+	Token
+	End int
+}
+
+func collectTokens(t *Tokeniser) ([]spanToken, error) {
+	var tokens []spanToken
+
+	for tk := range t.Iter {
+		tokens = append(tokens, spanToken{Token: tk, End: t.end})
+	}
+
+	return tokens, t.Err
+}
+
+func TestParallelTokeniser(t *testing.T) {
+	for n, input := range [...]string{
+		"",
+		"a b c",
+		"abc def\nghi \"jk\nl\" mno\npqr\n\"s\nt\nu\"\nvwx yz\n",
+		"a\n%%\nb \"c\nd\n%%\ne \"f\n\"\ng\n",
+		strings.Repeat("ab \"c\nd\" e\n%%\nf\ng\n%%\nh i\n", 20),
+		"a\nb\n\"c\nd\ne",
+	} {
+		st := NewByteTokeniser([]byte(input))
+
+		st.TokeniserState(parallelCode)
+
+		expected, expectedErr := collectTokens(&st)
+
+		for chunks := range 12 {
+			pt := NewParallelTokeniser([]byte(input), parallelCode, ParallelOptions{
+				Chunks:   chunks + 1,
+				Boundary: parallelLines,
+				Equal:    probeEqual("a b\n"),
+			})
+
+			if tokens, err := collectTokens(&pt); !reflect.DeepEqual(tokens, expected) {
+				t.Errorf("test %d (%d chunks): expecting tokens %v, got %v", n+1, chunks+1, expected, tokens)
+			} else if !errors.Is(err, expectedErr) {
+				t.Errorf("test %d (%d chunks): expecting error %v, got %v", n+1, chunks+1, expectedErr, err)
+			}
+		}
+	}
+}
+
+func TestParallelTokeniserStream(t *testing.T) {
+	release := make(chan struct{})
+
+	var blocking TokenFunc
+
+	blocking = func(t *Tokeniser) (Token, TokenFunc) {
+		if t.AcceptString("wait", false) == 4 {
+			<-release
+		}
+
+		tk, next := parallelCode(t)
+		if tk.Type >= 0 {
+			next = blocking
+		}
+
+		return tk, next
+	}
+
+	first := make(chan Token)
+
+	go func() {
+		pt := NewParallelTokeniser([]byte("a\nb\nc\nwait\n"), blocking, ParallelOptions{
+			Chunks: 4,
+			Boundary: func(data []byte, offset int) (int, TokenFunc) {
+				n, _ := parallelLines(data, offset)
+
+				return n, blocking
+			},
+			Equal: probeEqual("a b\n"),
+		})
+
+		tk, _ := pt.GetToken()
+		first <- tk
+
+		for range pt.Iter {
+		}
+	}()
+
+	select {
+	case tk := <-first:
+		if tk != (Token{Type: 4, Data: "a"}) {
+			t.Errorf("expecting first token %v, got %v", Token{Type: 4, Data: "a"}, tk)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("first token not returned before later chunks finished")
+	}
+
+	close(release)
+}
+
+func TestParallelTokeniserParser(t *testing.T) {
+	p := New(NewParallelTokeniser([]byte("a b\n\"c\nd\"\ne\n"), parallelCode, ParallelOptions{
+		Chunks:   4,
+		Boundary: parallelLines,
+		Equal:    probeEqual("a b\n"),
+	}))
+
+	expected := []Span{
+		{Start: 0, End: 1},
+		{Start: 1, End: 2},
+		{Start: 2, End: 3},
+		{Start: 3, End: 4},
+		{Start: 4, End: 9},
+		{Start: 9, End: 10},
+		{Start: 10, End: 11},
+		{Start: 11, End: 12},
+		{Start: 12, End: 12},
+	}
+
+	for n, span := range expected {
+		if offset := p.Offset(); offset != span.Start {
+			t.Errorf("test %d: expecting start %d, got %d", n+1, span.Start, offset)
+		}
+
+		p.Next()
+
+		if end := p.End(); end != span.End {
+			t.Errorf("test %d: expecting end %d, got %d", n+1, span.End, end)
+		}
+	}
+
+	if tk, err := p.GetToken(); tk.Type != TokenDone || err != nil {
+		t.Errorf("expecting TokenDone and nil error, got %v and %v", tk, err)
+	} else if !errors.Is(p.Err, io.EOF) {
+		t.Errorf("expecting error %v, got %v", io.EOF, p.Err)
+	}
+}
+
+func parallelDepth(depth int) TokenFunc {
+	return func(t *Tokeniser) (Token, TokenFunc) {
+		switch {
+		case t.Peek() == -1:
+			return t.Done()
+		case t.Accept(" \n"):
+			t.AcceptRun(" \n")
+
+			return t.Return(0, parallelDepth(depth))
+		case t.Accept("("):
+			return t.Return(1, parallelDepth(depth+1))
+		case t.Accept(")"):
+			if depth == 0 {
+				return t.ReturnError(ErrUnexpectedToken)
+			}
+
+			return t.Return(2, parallelDepth(depth-1))
+		}
+
+		t.ExceptRun(" \n()")
+
+		return t.Return(TokenType(10+depth), parallelDepth(depth))
+	}
+}
+
+func parallelDepthLines(data []byte, offset int) (int, TokenFunc) {
+	n, _ := parallelLines(data, offset)
+
+	return n, parallelDepth(0)
+}
+
+func TestParallelTokeniserClosures(t *testing.T) {
+	input := []byte(strings.Repeat("a (b\nc (d)\ne)\nf\n", 10) + "(g\n)\n)")
+	st := NewByteTokeniser(input)
+
+	st.TokeniserState(parallelDepth(0))
+
+	expected, expectedErr := collectTokens(&st)
+
+	for chunks := range 12 {
+		pt := NewParallelTokeniser(input, parallelDepth(0), ParallelOptions{
+			Chunks:   chunks + 1,
+			Boundary: parallelDepthLines,
+			Equal:    probeEqual("x"),
+		})
+
+		if tokens, err := collectTokens(&pt); !reflect.DeepEqual(tokens, expected) {
+			t.Errorf("test %d: expecting tokens %v, got %v", chunks+1, expected, tokens)
+		} else if !errors.Is(err, expectedErr) {
+			t.Errorf("test %d: expecting error %v, got %v", chunks+1, expectedErr, err)
+		}
+	}
+}
+
+func TestParallelTokeniserNoEqual(t *testing.T) {
+	pt := NewParallelTokeniser([]byte("a\nb\n"), parallelCode, ParallelOptions{
+		Chunks:   2,
+		Boundary: parallelLines,
+	})
+
+	expected := []spanToken{{Token: Token{Type: TokenError, Data: ErrNoEqual.Error()}}}
+
+	if tokens, err := collectTokens(&pt); !reflect.DeepEqual(tokens, expected) {
+		t.Errorf("expecting tokens %v, got %v", expected, tokens)
+	} else if !errors.Is(err, ErrNoEqual) {
+		t.Errorf("expecting error %v, got %v", ErrNoEqual, err)
+	}
+}
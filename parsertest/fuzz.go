@@ -0,0 +1,225 @@
+package parsertest
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"vimagination.zapto.org/parser"
+)
+
+// Timeout is the maximum time CheckInvariants will wait for a single
+// tokenising run to finish before deciding that it will not terminate.
+//
+// As a goroutine cannot be stopped, a tokenising run that times out is left
+// running in the background.
+var Timeout = 5 * time.Second
+
+// MaxEmpty is the maximum number of consecutive zero-length Tokens that
+// CheckInvariants will accept at a single offset before deciding that the
+// tokeniser is not making progress.
+var MaxEmpty = 100
+
+// CheckInvariants runs the given TokenFunc over the input, using each of the
+// Tokenisers backends, and checks that the following hold:
+//
+//  1. The tokeniser terminates, with either a TokenDone or TokenError Token.
+//  2. The Data of each Token matches the input at the position of that Token,
+//     so that concatenating the Data of all Tokens reproduces the input, or a
+//     prefix of it when the stream ends with a TokenError.
+//  3. No more than MaxEmpty zero-length Tokens are generated consecutively
+//     without the tokeniser making progress.
+//  4. All backends produce identical Token streams.
+//
+// When the input is not valid UTF-8, the io.Reader based backends, which
+// cannot reproduce invalid byte sequences, are not used.
+//
+// The error returned describes the first invariant found to not hold.
+//
+// The names, which may be nil, are used to name TokenTypes in the error.
+func CheckInvariants(names *parser.Names, tf parser.TokenFunc, input string) error {
+	var (
+		first   []parser.Token
+		initial string
+	)
+
+	for backend, tk := range tokenisers(input, utf8.ValidString(input)) {
+		tokens, err := collect(names, tk, tf, input)
+		if err != nil {
+			return fmt.Errorf("%s: %w", backend, err)
+		}
+
+		if first == nil {
+			first, initial = tokens, backend
+		} else if diff := diffTokens(names, first, tokens, 0, Position{Line: 1, Column: 1}); diff != "" {
+			return fmt.Errorf("%s: %w from %s backend: %s", backend, ErrBackendMismatch, initial, diff)
+		}
+	}
+
+	return nil
+}
+
+type result struct {
+	tokens []parser.Token
+	err    error
+}
+
+func collect(names *parser.Names, tk parser.Tokeniser, tf parser.TokenFunc, input string) ([]parser.Token, error) {
+	done := make(chan result, 1)
+
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- result{err: fmt.Errorf("%w: %v", ErrPanic, r)}
+			}
+		}()
+
+		tokens, err := checkStream(names, tk, tf, input)
+
+		done <- result{tokens: tokens, err: err}
+	}()
+
+	timer := time.NewTimer(Timeout)
+	defer timer.Stop()
+
+	select {
+	case r := <-done:
+		return r.tokens, r.err
+	case <-timer.C:
+		return nil, ErrTimeout
+	}
+}
+
+func checkStream(names *parser.Names, tk parser.Tokeniser, tf parser.TokenFunc, input string) ([]parser.Token, error) {
+	var (
+		tokens []parser.Token
+		pos    = Position{Line: 1, Column: 1}
+		empty  int
+	)
+
+	tk.TokeniserState(tf)
+
+	for {
+		t, _ := tk.GetToken()
+		tokens = append(tokens, t)
+
+		switch t.Type {
+		case parser.TokenDone:
+			if pos.Offset != len(input) {
+				return tokens, fmt.Errorf("token %d at %s: %w", len(tokens)-1, pos, ErrIncomplete)
+			}
+
+			return tokens, nil
+		case parser.TokenError:
+			return tokens, nil
+		}
+
+		if !strings.HasPrefix(input[pos.Offset:], t.Data) {
+			return tokens, fmt.Errorf("token %d at %s: %w: %s", len(tokens)-1, pos, ErrDataMismatch, FormatToken(names, t))
+		}
+
+		if t.Data != "" {
+			empty = 0
+		} else if empty++; empty > MaxEmpty {
+			return tokens, fmt.Errorf("token %d at %s: %w: %s", len(tokens)-1, pos, ErrNoProgress, FormatToken(names, t))
+		}
+
+		pos = pos.Advance(t)
+	}
+}
+
+// Minimise attempts to find the smallest input, derived by removing runes, or
+// bytes that are not part of a valid UTF-8 encoding, from the given input, for
+// which CheckInvariants still returns an error.
+//
+// If the given input does not cause CheckInvariants to return an error, it is
+// returned unchanged.
+//
+// To bound the time spent, and the number of tokenising runs left running,
+// inputs that cause a timeout are not minimised, and minimisation stops at the
+// first candidate input that causes a timeout.
+func Minimise(names *parser.Names, tf parser.TokenFunc, input string) string {
+	minimal, _ := minimise(names, tf, input, CheckInvariants(names, tf, input))
+
+	return minimal
+}
+
+// minimise minimises an input that caused the given error, returning the
+// minimal input and the error it causes.
+func minimise(names *parser.Names, tf parser.TokenFunc, input string, err error) (string, error) {
+	if err == nil || errors.Is(err, ErrTimeout) {
+		return input, err
+	}
+
+	original := err
+	pieces := split(input)
+	minimal := input
+
+	for chunk := max(len(pieces)/2, 1); chunk > 0; chunk /= 2 {
+		for start := 0; start < len(pieces); {
+			candidate := append(pieces[:start:start], pieces[min(start+chunk, len(pieces)):]...)
+
+			if cerr := CheckInvariants(names, tf, strings.Join(candidate, "")); errors.Is(cerr, ErrTimeout) {
+				return minimal, err
+			} else if cerr != nil {
+				pieces, err = candidate, cerr
+				minimal = strings.Join(pieces, "")
+			} else {
+				start += chunk
+			}
+		}
+	}
+
+	if CheckInvariants(names, tf, minimal) == nil {
+		return input, original
+	}
+
+	return minimal, err
+}
+
+// split splits a string into its encoded runes, with each byte that is not
+// part of a valid UTF-8 encoding kept as its own piece.
+func split(input string) []string {
+	var pieces []string
+
+	for len(input) > 0 {
+		_, size := utf8.DecodeRuneInString(input)
+		pieces = append(pieces, input[:size])
+		input = input[size:]
+	}
+
+	return pieces
+}
+
+// Fuzz adds the seeds to the corpus of the fuzz test and fuzzes the given
+// TokenFunc, failing when CheckInvariants returns an error.
+//
+// Upon failure, the reported input is minimised as with Minimise.
+func Fuzz(f *testing.F, names *parser.Names, tf parser.TokenFunc, seeds ...string) {
+	f.Helper()
+
+	for _, seed := range seeds {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, input string) {
+		if err := CheckInvariants(names, tf, input); err != nil {
+			minimal, merr := minimise(names, tf, input, err)
+
+			t.Fatalf("input %q: %s\nminimal failing input %q: %s", input, err, minimal, merr)
+		}
+	})
+}
+
+// Errors.
+var (
+	ErrBackendMismatch = errors.New("token stream differs")
+	ErrDataMismatch    = errors.New("token data does not match input")
+	ErrIncomplete      = errors.New("tokeniser finished before consuming all input")
+	ErrNoProgress      = errors.New("too many zero-length tokens without progress")
+	ErrPanic           = errors.New("tokeniser panicked")
+	ErrTimeout         = errors.New("tokeniser did not terminate")
+)
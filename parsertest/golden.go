@@ -0,0 +1,162 @@
+package parsertest
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"vimagination.zapto.org/parser"
+)
+
+// Update determines whether golden files are regenerated, instead of being
+// compared against.
+//
+// It is set by the -parsertest.update flag of the test binary, and may also be
+// set directly, such as from a flag declared by the test package.
+var Update bool
+
+func init() {
+	flag.BoolVar(&Update, "parsertest.update", false, "regenerate parsertest golden files")
+}
+
+// WriteTokens writes the Tokens to the Writer in the golden file format.
+//
+// Each Token is written on its own line, as the line:column position, the byte
+// offset, the type name and the quoted data, separated by tabs.
+func WriteTokens(w io.Writer, names *parser.Names, tokens []parser.Token) error {
+	_, err := writeTokens(w, names, tokens, "", Position{Line: 1, Column: 1})
+
+	return err
+}
+
+func writeTokens(w io.Writer, names *parser.Names, tokens []parser.Token, indent string, pos Position) (Position, error) {
+	for _, tk := range tokens {
+		if _, err := fmt.Fprintf(w, "%s%d:%d\t%d\t%s\t%s\n", indent, pos.Line, pos.Column, pos.Offset, names.Token(tk.Type), strconv.Quote(tk.Data)); err != nil {
+			return pos, err
+		}
+
+		pos = pos.Advance(tk)
+	}
+
+	return pos, nil
+}
+
+// WritePhrases writes the Phrases to the Writer in the golden file format.
+//
+// Each Phrase is written as a line containing the type name and the position
+// of the Phrase, followed by its Tokens, indented by a tab, in the format used
+// by WriteTokens.
+func WritePhrases(w io.Writer, names *parser.Names, phrases []parser.Phrase) error {
+	pos := Position{Line: 1, Column: 1}
+
+	for _, ph := range phrases {
+		if _, err := fmt.Fprintf(w, "%s\t%d:%d\t%d\n", names.Phrase(ph.Type), pos.Line, pos.Column, pos.Offset); err != nil {
+			return err
+		}
+
+		var err error
+
+		if pos, err = writeTokens(w, names, ph.Data, "\t", pos); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GoldenTokens runs the given TokenFunc over the contents of the input file,
+// using each of the Tokenisers backends, and compares the generated Tokens,
+// formatted by WriteTokens, against the contents of the golden file, which has
+// the same path as the input file with ".golden" appended.
+//
+// When Update is true, such as when the test binary is run with the
+// -parsertest.update flag, the golden file is regenerated instead.
+func GoldenTokens(t testing.TB, names *parser.Names, tf parser.TokenFunc, input string) {
+	t.Helper()
+
+	golden(t, input, func(tk parser.Tokeniser, w io.Writer) error {
+		return WriteTokens(w, names, Tokens(tk, tf))
+	})
+}
+
+// GoldenPhrases runs the given TokenFunc and PhraseFunc over the contents of
+// the input file, using each of the Tokenisers backends, and compares the
+// generated Phrases, formatted by WritePhrases, against the contents of the
+// golden file, which has the same path as the input file with ".golden"
+// appended.
+//
+// When Update is true, such as when the test binary is run with the
+// -parsertest.update flag, the golden file is regenerated instead.
+func GoldenPhrases(t testing.TB, names *parser.Names, tf parser.TokenFunc, pf parser.PhraseFunc, input string) {
+	t.Helper()
+
+	golden(t, input, func(tk parser.Tokeniser, w io.Writer) error {
+		return WritePhrases(w, names, Phrases(tk, tf, pf))
+	})
+}
+
+func golden(t testing.TB, input string, format func(parser.Tokeniser, io.Writer) error) {
+	t.Helper()
+
+	data, err := os.ReadFile(input)
+	if err != nil {
+		t.Fatalf("error reading input file: %s", err)
+	}
+
+	goldenFile := input + ".golden"
+
+	var (
+		expected []byte
+		buf      bytes.Buffer
+	)
+
+	if !Update {
+		if expected, err = os.ReadFile(goldenFile); errors.Is(err, os.ErrNotExist) {
+			t.Fatalf("golden file %s does not exist, run with -parsertest.update to create it", goldenFile)
+		} else if err != nil {
+			t.Fatalf("error reading golden file: %s", err)
+		}
+	}
+
+	for backend, tk := range Tokenisers(string(data)) {
+		buf.Reset()
+
+		if err := format(tk, &buf); err != nil {
+			t.Fatalf("%s: error formatting output: %s", backend, err)
+		}
+
+		if Update && expected == nil {
+			if err := os.WriteFile(goldenFile, buf.Bytes(), 0o644); err != nil {
+				t.Fatalf("error writing golden file: %s", err)
+			}
+
+			expected = append([]byte{}, buf.Bytes()...)
+		} else if diff := diffLines(string(expected), buf.String()); diff != "" {
+			t.Errorf("%s: %s:%s", backend, goldenFile, diff)
+		}
+	}
+}
+
+func diffLines(expected, got string) string {
+	el := strings.SplitAfter(expected, "\n")
+	gl := strings.SplitAfter(got, "\n")
+
+	for n := range max(len(el), len(gl)) {
+		switch {
+		case n >= len(el):
+			return fmt.Sprintf("%d: unexpected extra line %q", n+1, gl[n])
+		case n >= len(gl):
+			return fmt.Sprintf("%d: missing line %q", n+1, el[n])
+		case el[n] != gl[n]:
+			return fmt.Sprintf("%d: expecting %q, got %q", n+1, el[n], gl[n])
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,193 @@
+// Package parsertest provides helpers for testing TokenFuncs and PhraseFuncs.
+package parsertest // import "vimagination.zapto.org/parser/parsertest"
+
+import (
+	"fmt"
+	"iter"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"vimagination.zapto.org/parser"
+)
+
+// Tokenisers yields a named Tokeniser for each of the parser backends, all
+// reading from the given string.
+func Tokenisers(str string) iter.Seq2[string, parser.Tokeniser] {
+	return tokenisers(str, true)
+}
+
+// tokenisers yields the named Tokenisers, skipping the io.Reader based
+// backends when readers is false.
+func tokenisers(str string, readers bool) iter.Seq2[string, parser.Tokeniser] {
+	return func(yield func(string, parser.Tokeniser) bool) {
+		_ = yield("string", parser.NewStringTokeniser(str)) &&
+			yield("bytes", parser.NewByteTokeniser([]byte(str))) &&
+			(!readers || yield("reader", parser.NewReaderTokeniser(strings.NewReader(str))) &&
+				yield("rune reader", parser.NewRuneReaderTokeniser(strings.NewReader(str)))) &&
+			yield("sub (string)", sub(parser.NewStringTokeniser(str))) &&
+			yield("sub (bytes)", sub(parser.NewByteTokeniser([]byte(str)))) &&
+			(!readers || yield("sub (reader)", sub(parser.NewReaderTokeniser(strings.NewReader(str)))) &&
+				yield("sub (rune reader)", sub(parser.NewRuneReaderTokeniser(strings.NewReader(str)))))
+	}
+}
+
+func sub(t parser.Tokeniser) parser.Tokeniser {
+	return *t.SubTokeniser()
+}
+
+// Tokens runs the given TokenFunc over the Tokeniser, returning all of the
+// generated Tokens, up to and including the TokenDone or TokenError Token.
+func Tokens(t parser.Tokeniser, tf parser.TokenFunc) []parser.Token {
+	var tokens []parser.Token
+
+	t.TokeniserState(tf)
+
+	for tk := range t.Iter {
+		tokens = append(tokens, tk)
+	}
+
+	return tokens
+}
+
+// Phrases runs the given TokenFunc and PhraseFunc over the Tokeniser,
+// returning all of the generated Phrases, up to and including the PhraseDone
+// or PhraseError Phrase.
+func Phrases(t parser.Tokeniser, tf parser.TokenFunc, pf parser.PhraseFunc) []parser.Phrase {
+	var phrases []parser.Phrase
+
+	p := parser.New(t)
+
+	p.TokeniserState(tf)
+	p.PhraserState(pf)
+
+	for ph := range p.Iter {
+		phrases = append(phrases, ph)
+	}
+
+	return phrases
+}
+
+// CheckTokens runs the given TokenFunc over the input, using each of the
+// Tokenisers backends, and reports any difference between the generated
+// Tokens and the expected Tokens.
+//
+// The expected Tokens should include the final TokenDone or TokenError Token.
+//
+// The names, which may be nil, are used to name TokenTypes in failure
+// messages.
+func CheckTokens(t testing.TB, names *parser.Names, input string, tf parser.TokenFunc, expected []parser.Token) {
+	t.Helper()
+
+	for backend, tk := range Tokenisers(input) {
+		if err := diffTokens(names, expected, Tokens(tk, tf), 0, Position{Line: 1, Column: 1}); err != "" {
+			t.Errorf("%s: %s", backend, err)
+		}
+	}
+}
+
+// CheckPhrases runs the given TokenFunc and PhraseFunc over the input, using
+// each of the Tokenisers backends, and reports any difference between the
+// generated Phrases and the expected Phrases.
+//
+// The expected Phrases should include the final PhraseDone or PhraseError
+// Phrase.
+//
+// The names, which may be nil, are used to name TokenTypes and PhraseTypes in
+// failure messages.
+func CheckPhrases(t testing.TB, names *parser.Names, input string, tf parser.TokenFunc, pf parser.PhraseFunc, expected []parser.Phrase) {
+	t.Helper()
+
+	for backend, tk := range Tokenisers(input) {
+		if err := diffPhrases(names, expected, Phrases(tk, tf, pf)); err != "" {
+			t.Errorf("%s: %s", backend, err)
+		}
+	}
+}
+
+func diffTokens(names *parser.Names, expected, got []parser.Token, start int, pos Position) string {
+	for n, tk := range got {
+		if n >= len(expected) {
+			return fmt.Sprintf("token %d at %s: unexpected extra token %s", start+n, pos, FormatToken(names, tk))
+		} else if tk != expected[n] {
+			return fmt.Sprintf("token %d at %s: expecting %s, got %s", start+n, pos, FormatToken(names, expected[n]), FormatToken(names, tk))
+		}
+
+		pos = pos.Advance(tk)
+	}
+
+	if len(got) < len(expected) {
+		return fmt.Sprintf("token %d at %s: missing token %s", start+len(got), pos, FormatToken(names, expected[len(got)]))
+	}
+
+	return ""
+}
+
+func diffPhrases(names *parser.Names, expected, got []parser.Phrase) string {
+	var (
+		token int
+		pos   = Position{Line: 1, Column: 1}
+	)
+
+	for n, ph := range got {
+		if n >= len(expected) {
+			return fmt.Sprintf("phrase %d at %s: unexpected extra phrase %s", n, pos, names.Phrase(ph.Type))
+		} else if ph.Type != expected[n].Type {
+			return fmt.Sprintf("phrase %d at %s: expecting phrase type %s, got %s", n, pos, names.Phrase(expected[n].Type), names.Phrase(ph.Type))
+		} else if err := diffTokens(names, expected[n].Data, ph.Data, token, pos); err != "" {
+			return fmt.Sprintf("phrase %d (%s): %s", n, names.Phrase(ph.Type), err)
+		}
+
+		for _, tk := range ph.Data {
+			pos = pos.Advance(tk)
+		}
+
+		token += len(ph.Data)
+	}
+
+	if len(got) < len(expected) {
+		return fmt.Sprintf("phrase %d at %s: missing phrase %s", len(got), pos, names.Phrase(expected[len(got)].Type))
+	}
+
+	return ""
+}
+
+// FormatToken returns a readable representation of a Token, consisting of the
+// name of its type and its quoted data.
+func FormatToken(names *parser.Names, tk parser.Token) string {
+	return names.Token(tk.Type) + " " + strconv.Quote(tk.Data)
+}
+
+// Position represents the location of a Token within the input.
+//
+// Line and Column are 1-indexed, with Column counted in runes.
+type Position struct {
+	Offset, Line, Column int
+}
+
+// Advance returns the Position immediately after the given Token, which is
+// assumed to start at the current Position.
+//
+// TokenDone and TokenError Tokens do not advance the Position.
+func (p Position) Advance(tk parser.Token) Position {
+	if tk.Type < 0 {
+		return p
+	}
+
+	p.Offset += len(tk.Data)
+
+	if n := strings.LastIndexByte(tk.Data, '\n'); n >= 0 {
+		p.Line += strings.Count(tk.Data, "\n")
+		p.Column = 1 + utf8.RuneCountInString(tk.Data[n+1:])
+	} else {
+		p.Column += utf8.RuneCountInString(tk.Data)
+	}
+
+	return p
+}
+
+// String formats the Position as line:column (offset).
+func (p Position) String() string {
+	return fmt.Sprintf("%d:%d (offset %d)", p.Line, p.Column, p.Offset)
+}
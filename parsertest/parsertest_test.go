@@ -0,0 +1,424 @@
+package parsertest
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"vimagination.zapto.org/parser"
+)
+
+const (
+	tokenWord parser.TokenType = iota
+	tokenWhitespace
+)
+
+const phraseLine parser.PhraseType = iota
+
+// update checks that packages importing parsertest can declare their own
+// -update flag.
+var update = flag.Bool("update", false, "update test files")
+
+var names = &parser.Names{
+	Tokens: map[parser.TokenType]string{
+		tokenWord:       "Word",
+		tokenWhitespace: "Whitespace",
+	},
+	Phrases: map[parser.PhraseType]string{
+		phraseLine: "Line",
+	},
+}
+
+func words(t *parser.Tokeniser) (parser.Token, parser.TokenFunc) {
+	if t.Peek() == -1 {
+		return t.Done()
+	} else if t.AcceptRun(" \n"); t.Len() > 0 {
+		return t.Return(tokenWhitespace, words)
+	}
+
+	t.ExceptRun(" \n")
+
+	return t.Return(tokenWord, words)
+}
+
+func lines(p *parser.Parser) (parser.Phrase, parser.PhraseFunc) {
+	if p.Peek().Type == parser.TokenDone {
+		return p.Done()
+	}
+
+	for !p.AcceptToken(parser.Token{Type: tokenWhitespace, Data: "\n"}) && p.Peek().Type != parser.TokenDone {
+		p.Next()
+	}
+
+	return p.Return(phraseLine, lines)
+}
+
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestCheckTokens(t *testing.T) {
+	CheckTokens(t, names, "Hello World", words, []parser.Token{
+		{Type: tokenWord, Data: "Hello"},
+		{Type: tokenWhitespace, Data: " "},
+		{Type: tokenWord, Data: "World"},
+		{Type: parser.TokenDone, Data: ""},
+	})
+
+	var r recorder
+
+	CheckTokens(&r, names, "Hello\nBeautiful World", words, []parser.Token{
+		{Type: tokenWord, Data: "Hello"},
+		{Type: tokenWhitespace, Data: "\n"},
+		{Type: tokenWord, Data: "Beautiful"},
+		{Type: tokenWord, Data: " "},
+	})
+
+	if len(r.errors) != 8 {
+		t.Fatalf("expecting 8 errors, got %d", len(r.errors))
+	}
+
+	if expected := "string: token 3 at 2:10 (offset 15): expecting Word \" \", got Whitespace \" \""; r.errors[0] != expected {
+		t.Errorf("expecting error %q, got %q", expected, r.errors[0])
+	}
+
+	if expected := "sub (rune reader): token 3 at 2:10 (offset 15): expecting Word \" \", got Whitespace \" \""; r.errors[7] != expected {
+		t.Errorf("expecting error %q, got %q", expected, r.errors[7])
+	}
+
+	r.errors = r.errors[:0]
+
+	CheckTokens(&r, names, "A B", words, []parser.Token{
+		{Type: tokenWord, Data: "A"},
+		{Type: tokenWhitespace, Data: " "},
+		{Type: tokenWord, Data: "B"},
+	})
+
+	if len(r.errors) != 8 {
+		t.Fatalf("expecting 8 errors, got %d", len(r.errors))
+	}
+
+	if expected := "bytes: token 3 at 1:4 (offset 3): unexpected extra token TokenDone \"\""; r.errors[1] != expected {
+		t.Errorf("expecting error %q, got %q", expected, r.errors[1])
+	}
+}
+
+func TestCheckPhrases(t *testing.T) {
+	CheckPhrases(t, names, "A B\nC", words, lines, []parser.Phrase{
+		{
+			Type: phraseLine,
+			Data: []parser.Token{
+				{Type: tokenWord, Data: "A"},
+				{Type: tokenWhitespace, Data: " "},
+				{Type: tokenWord, Data: "B"},
+				{Type: tokenWhitespace, Data: "\n"},
+			},
+		},
+		{
+			Type: phraseLine,
+			Data: []parser.Token{
+				{Type: tokenWord, Data: "C"},
+			},
+		},
+		{
+			Type: parser.PhraseDone,
+			Data: []parser.Token{},
+		},
+	})
+
+	var r recorder
+
+	CheckPhrases(&r, names, "A B\nC", words, lines, []parser.Phrase{
+		{
+			Type: phraseLine,
+			Data: []parser.Token{
+				{Type: tokenWord, Data: "A"},
+				{Type: tokenWhitespace, Data: " "},
+				{Type: tokenWord, Data: "B"},
+				{Type: tokenWhitespace, Data: "\n"},
+			},
+		},
+		{
+			Type: phraseLine,
+			Data: []parser.Token{
+				{Type: tokenWord, Data: "D"},
+			},
+		},
+	})
+
+	if len(r.errors) != 8 {
+		t.Fatalf("expecting 8 errors, got %d", len(r.errors))
+	}
+
+	if expected := "reader: phrase 1 (Line): token 4 at 2:1 (offset 4): expecting Word \"D\", got Word \"C\""; r.errors[2] != expected {
+		t.Errorf("expecting error %q, got %q", expected, r.errors[2])
+	}
+}
+
+func TestUpdateFlag(t *testing.T) {
+	if f := flag.Lookup("parsertest.update"); f == nil {
+		t.Errorf("expecting parsertest.update flag")
+	} else if *update || Update {
+		t.Errorf("expecting update flags to be false")
+	}
+}
+
+func TestGoldenTokens(t *testing.T) {
+	GoldenTokens(t, names, words, "testdata/words.txt")
+}
+
+func TestGoldenPhrases(t *testing.T) {
+	GoldenPhrases(t, names, words, lines, "testdata/lines.txt")
+}
+
+func TestDiffLines(t *testing.T) {
+	for n, test := range [...]struct {
+		Expected, Got, Diff string
+	}{
+		{
+			Expected: "a\nb\n",
+			Got:      "a\nb\n",
+		},
+		{
+			Expected: "a\nb\n",
+			Got:      "a\nc\n",
+			Diff:     "2: expecting \"b\\n\", got \"c\\n\"",
+		},
+		{
+			Expected: "a\n",
+			Got:      "a\nb",
+			Diff:     "2: expecting \"\", got \"b\"",
+		},
+		{
+			Expected: "a\nb\n",
+			Got:      "a\n",
+			Diff:     "2: expecting \"b\\n\", got \"\"",
+		},
+	} {
+		if diff := diffLines(test.Expected, test.Got); diff != test.Diff {
+			t.Errorf("test %d: expecting diff %q, got %q", n+1, test.Diff, diff)
+		}
+	}
+}
+
+func FuzzWords(f *testing.F) {
+	Fuzz(f, names, words, "", "Hello World", "A  B\n\nC")
+}
+
+func noProgress(t *parser.Tokeniser) (parser.Token, parser.TokenFunc) {
+	t.AcceptRun(" ")
+
+	return t.Return(tokenWhitespace, noProgress)
+}
+
+func changing() parser.TokenFunc {
+	var typ parser.TokenType
+
+	return func(t *parser.Tokeniser) (parser.Token, parser.TokenFunc) {
+		t.ExceptRun("")
+
+		typ++
+
+		return t.Return(typ, nil)
+	}
+}
+
+func TestCheckInvariants(t *testing.T) {
+	for n, test := range [...]struct {
+		Input     string
+		TokenFunc parser.TokenFunc
+		Err       error
+	}{
+		{
+			Input:     "Hello World",
+			TokenFunc: words,
+		},
+		{
+			Input: "ABC",
+			TokenFunc: func(t *parser.Tokeniser) (parser.Token, parser.TokenFunc) {
+				t.Next()
+
+				return t.Done()
+			},
+			Err: ErrIncomplete,
+		},
+		{
+			Input: "ABC",
+			TokenFunc: func(t *parser.Tokeniser) (parser.Token, parser.TokenFunc) {
+				t.Next()
+
+				return parser.Token{Type: tokenWord, Data: "B"}, nil
+			},
+			Err: ErrDataMismatch,
+		},
+		{
+			Input:     "ABC",
+			TokenFunc: noProgress,
+			Err:       ErrNoProgress,
+		},
+		{
+			Input: "ABC",
+			TokenFunc: func(t *parser.Tokeniser) (parser.Token, parser.TokenFunc) {
+				panic("oops")
+			},
+			Err: ErrPanic,
+		},
+		{
+			Input:     "AB",
+			TokenFunc: changing(),
+			Err:       ErrBackendMismatch,
+		},
+		{
+			Input:     "A\xffB \xfe",
+			TokenFunc: words,
+		},
+		{
+			Input: "A\xffB",
+			TokenFunc: func(t *parser.Tokeniser) (parser.Token, parser.TokenFunc) {
+				t.ExceptRun("")
+				t.Get()
+
+				return parser.Token{Type: tokenWord, Data: "A\uFFFDB"}, nil
+			},
+			Err: ErrDataMismatch,
+		},
+	} {
+		if err := CheckInvariants(names, test.TokenFunc, test.Input); !errors.Is(err, test.Err) {
+			t.Errorf("test %d: expecting error %v, got %v", n+1, test.Err, err)
+		}
+	}
+}
+
+func TestMinimise(t *testing.T) {
+	var spaces parser.TokenFunc
+
+	spaces = func(t *parser.Tokeniser) (parser.Token, parser.TokenFunc) {
+		if t.Peek() == -1 {
+			return t.Done()
+		} else if t.Accept(" ") {
+			return t.Return(tokenWhitespace, spaces)
+		}
+
+		t.ExceptRun("!")
+
+		return t.Return(tokenWord, spaces)
+	}
+
+	if got := Minimise(names, spaces, "Hello, World! How are you?"); got != "!" {
+		t.Errorf("expecting minimal input %q, got %q", "!", got)
+	}
+
+	if got := Minimise(names, words, "Hello, World!"); got != "Hello, World!" {
+		t.Errorf("expecting input to be unchanged, got %q", got)
+	}
+}
+
+func TestMinimiseTimeout(t *testing.T) {
+	var (
+		stop  atomic.Bool
+		spins atomic.Int32
+	)
+
+	defer func(timeout time.Duration) {
+		Timeout = timeout
+
+		stop.Store(true)
+	}(Timeout)
+
+	Timeout = 10 * time.Millisecond
+
+	tf := func(t *parser.Tokeniser) (parser.Token, parser.TokenFunc) {
+		t.ExceptRun("")
+
+		if t.Len() == 1 {
+			spins.Add(1)
+
+			for !stop.Load() {
+			}
+		}
+
+		return t.Done()
+	}
+
+	if got := Minimise(names, tf, "A"); got != "A" {
+		t.Errorf("test 1: expecting input to be unchanged, got %q", got)
+	} else if n := spins.Load(); n != 1 {
+		t.Errorf("test 1: expecting 1 timeout, got %d", n)
+	}
+
+	spins.Store(0)
+
+	if got := Minimise(names, tf, "ABCD"); got != "CD" {
+		t.Errorf("test 2: expecting minimal input %q, got %q", "CD", got)
+	} else if n := spins.Load(); n != 1 {
+		t.Errorf("test 2: expecting 1 timeout, got %d", n)
+	}
+}
+
+func TestMinimiseInvalidUTF8(t *testing.T) {
+	tf := func(t *parser.Tokeniser) (parser.Token, parser.TokenFunc) {
+		if t.Peek() == -1 {
+			return t.Done()
+		}
+
+		t.ExceptRun("")
+
+		data := t.Get()
+		if strings.Contains(data, "\xff") {
+			data += "!"
+		}
+
+		return parser.Token{Type: tokenWord, Data: data}, (*parser.Tokeniser).Done
+	}
+
+	if got := Minimise(names, tf, "abcdefgh\xffijk"); got != "\xff" {
+		t.Errorf("expecting minimal input %q, got %q", "\xff", got)
+	} else if err := CheckInvariants(names, tf, got); !errors.Is(err, ErrDataMismatch) {
+		t.Errorf("expecting minimal input to fail with ErrDataMismatch, got %v", err)
+	}
+}
+
+func lineWords(t *parser.Tokeniser) (parser.Token, parser.TokenFunc) {
+	if t.Peek() == -1 {
+		return t.Done()
+	}
+
+	t.ExceptRun(" \t\n")
+
+	return t.Return(tokenWord, lineWords)
+}
+
+func TestCheckInvariantsIndenter(t *testing.T) {
+	tf := func(t *parser.Tokeniser) (parser.Token, parser.TokenFunc) {
+		i := parser.Indenter{
+			Indent:         10,
+			Dedent:         11,
+			Newline:        12,
+			Trivia:         tokenWhitespace,
+			PreserveTrivia: true,
+		}
+
+		return i.Wrap(lineWords)(t)
+	}
+
+	for n, input := range [...]string{
+		"a:\n  b:\n    c\nd\n",
+		"a:\n  b:\n    c:\n      d",
+		"",
+	} {
+		if err := CheckInvariants(names, tf, input); err != nil {
+			t.Errorf("test %d: unexpected error: %s", n+1, err)
+		}
+	}
+}
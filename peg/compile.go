@@ -0,0 +1,771 @@
+package peg
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"vimagination.zapto.org/parser"
+	"vimagination.zapto.org/parser/combinator"
+)
+
+type kind uint8
+
+const (
+	kindToken kind = iota
+	kindFragment
+	kindPhrase
+)
+
+func ruleKind(name string) kind {
+	switch c := name[0]; {
+	case c == '_':
+		return kindFragment
+	case c >= 'A' && c <= 'Z':
+		return kindToken
+	}
+
+	return kindPhrase
+}
+
+type matcher func(*parser.Tokeniser) bool
+
+type children = []parser.Child
+
+type tokenRule struct {
+	typ   parser.TokenType
+	match matcher
+}
+
+// Compiled is a Grammar that has been compiled into a TokenFunc and, if the
+// Grammar contains phrase rules, a PhraseFunc.
+type Compiled struct {
+	// Names contains the names of the token rules, phrase rules and capture
+	// labels of the Grammar, mapped from their types.
+	Names *parser.Names
+
+	// TokenTypes maps the names of the token rules to their TokenTypes.
+	TokenTypes map[string]parser.TokenType
+
+	// PhraseTypes maps the names of the phrase rules and capture labels to
+	// their PhraseTypes.
+	PhraseTypes map[string]parser.PhraseType
+
+	tokenRules []tokenRule
+	start      combinator.Rule[*parser.Node]
+	startType  parser.PhraseType
+	end        combinator.Rule[[]children]
+}
+
+// Compile parses and compiles the grammar source.
+func Compile(src string) (*Compiled, error) {
+	g, err := Parse(src)
+	if err != nil {
+		return nil, err
+	}
+
+	return g.Compile()
+}
+
+type compiler struct {
+	*Compiled
+	rules   map[string]*Rule
+	skipped map[parser.TokenType]bool
+	lexical map[string]*matcher
+	phrase  map[string]*combinator.Rule[children]
+	skip    combinator.Rule[children]
+}
+
+// Compile compiles the Grammar.
+//
+// Token rules are assigned TokenTypes, starting at zero, in the order they are
+// defined; phrase rules, followed by capture labels, are likewise assigned
+// PhraseTypes.
+//
+// Grammars containing left recursion, in which a rule can reference itself,
+// directly or indirectly, without first consuming any input, are rejected.
+//
+// Errors will be of type *Error, giving the position of the error.
+func (g *Grammar) Compile() (*Compiled, error) {
+	c := compiler{
+		Compiled: &Compiled{
+			Names: &parser.Names{
+				Tokens:  make(map[parser.TokenType]string),
+				Phrases: make(map[parser.PhraseType]string),
+			},
+			TokenTypes:  make(map[string]parser.TokenType),
+			PhraseTypes: make(map[string]parser.PhraseType),
+		},
+		rules:   make(map[string]*Rule),
+		skipped: make(map[parser.TokenType]bool),
+		lexical: make(map[string]*matcher),
+		phrase:  make(map[string]*combinator.Rule[children]),
+	}
+
+	if err := c.declare(g.Rules); err != nil {
+		return nil, err
+	}
+
+	for _, r := range g.Rules {
+		if err := c.compileRule(r); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := checkLeftRecursion(g.Rules); err != nil {
+		return nil, err
+	}
+
+	return c.Compiled, nil
+}
+
+func (c *compiler) declare(rules []*Rule) error {
+	var skipTypes []parser.TokenType
+
+	for _, r := range rules {
+		if _, ok := c.rules[r.Name]; ok {
+			return &Error{Position: r.Position, Err: fmt.Errorf("%w: %s", ErrDuplicateRule, r.Name)}
+		}
+
+		c.rules[r.Name] = r
+
+		switch ruleKind(r.Name) {
+		case kindToken:
+			typ := parser.TokenType(len(c.TokenTypes))
+			c.TokenTypes[r.Name] = typ
+			c.Names.Tokens[typ] = r.Name
+			c.lexical[r.Name] = new(matcher)
+
+			if r.Skip {
+				c.skipped[typ] = true
+				skipTypes = append(skipTypes, typ)
+			}
+
+			continue
+		case kindFragment:
+			c.lexical[r.Name] = new(matcher)
+		case kindPhrase:
+			c.addPhraseType(r.Name)
+
+			c.phrase[r.Name] = new(combinator.Rule[children])
+		}
+
+		if r.Skip {
+			return &Error{Position: r.Position, Err: fmt.Errorf("%w: %s", ErrInvalidSkip, r.Name)}
+		}
+	}
+
+	if len(skipTypes) > 0 {
+		c.skip = combinator.Silent(combinator.Map(combinator.Many(tokenChild(combinator.Token(skipTypes...))), flatten))
+		c.end = combinator.Silent(combinator.Seq(c.skip, combinator.Map(combinator.Token(parser.TokenDone), func(parser.Token) children { return nil })))
+	}
+
+	return nil
+}
+
+func (c *compiler) addPhraseType(name string) parser.PhraseType {
+	if typ, ok := c.PhraseTypes[name]; ok {
+		return typ
+	}
+
+	typ := parser.PhraseType(len(c.PhraseTypes))
+	c.PhraseTypes[name] = typ
+	c.Names.Phrases[typ] = name
+
+	return typ
+}
+
+func (c *compiler) compileRule(r *Rule) error {
+	if ruleKind(r.Name) != kindPhrase {
+		m, err := c.compileLexical(r.Expr)
+		if err != nil {
+			return err
+		}
+
+		*c.lexical[r.Name] = m
+
+		if ruleKind(r.Name) == kindToken {
+			c.tokenRules = append(c.tokenRules, tokenRule{typ: c.TokenTypes[r.Name], match: m})
+		}
+
+		return nil
+	}
+
+	pr, err := c.compilePhrase(r.Expr)
+	if err != nil {
+		return err
+	}
+
+	typ := c.PhraseTypes[r.Name]
+
+	*c.phrase[r.Name] = combinator.Memo(node(typ, pr))
+
+	if c.start == nil {
+		c.startType = typ
+		c.start = c.startRule(*c.phrase[r.Name])
+	}
+
+	return nil
+}
+
+func (c *compiler) startRule(r combinator.Rule[children]) combinator.Rule[*parser.Node] {
+	rules := []combinator.Rule[children]{r}
+
+	if c.skip != nil {
+		rules = append(rules, c.skip)
+	}
+
+	seq := combinator.Map(combinator.Seq(rules...), func(cs []children) *parser.Node {
+		root := cs[0][0].Node
+
+		return &parser.Node{
+			Type:     root.Type,
+			Children: append(slices.Clone(root.Children), slices.Concat(cs[1:]...)...),
+		}
+	})
+
+	return func(in *combinator.Input) (*parser.Node, bool) {
+		pos := in.Pos()
+
+		n, ok := seq(in)
+		if ok && in.Pos() == pos {
+			return nil, false
+		}
+
+		return n, ok
+	}
+}
+
+func node(typ parser.PhraseType, r combinator.Rule[children]) combinator.Rule[children] {
+	return func(in *combinator.Input) (children, bool) {
+		cs, ok := r(in)
+		if !ok {
+			return nil, false
+		}
+
+		n := &parser.Node{Type: typ, Children: cs}
+
+		if len(cs) > 0 {
+			n.Span = parser.Span{Start: cs[0].Span.Start, End: cs[len(cs)-1].Span.End}
+		} else {
+			n.Span = parser.Span{Start: in.End(), End: in.End()}
+		}
+
+		return children{{Node: n, Span: n.Span}}, true
+	}
+}
+
+func tokenChild(r combinator.Rule[parser.Token]) combinator.Rule[children] {
+	return func(in *combinator.Input) (children, bool) {
+		start := in.Offset()
+
+		tk, ok := r(in)
+		if !ok {
+			return nil, false
+		}
+
+		return children{{Token: tk, Span: parser.Span{Start: start, End: in.End()}}}, true
+	}
+}
+
+func flatten(css []children) children {
+	return slices.Concat(css...)
+}
+
+func empty[T any](T) children {
+	return nil
+}
+
+func (c *compiler) compileLexical(e Expr) (matcher, error) {
+	switch e := e.(type) {
+	case *Choice:
+		ms, err := c.compileLexicals(e.Alternatives)
+		if err != nil {
+			return nil, err
+		}
+
+		return func(t *parser.Tokeniser) bool {
+			for _, m := range ms {
+				if m(t) {
+					return true
+				}
+			}
+
+			return false
+		}, nil
+	case *Sequence:
+		ms, err := c.compileLexicals(e.Items)
+		if err != nil {
+			return nil, err
+		}
+
+		return func(t *parser.Tokeniser) bool {
+			s := t.State()
+
+			for _, m := range ms {
+				if !m(t) {
+					s.Reset()
+
+					return false
+				}
+			}
+
+			return true
+		}, nil
+	case *Repeat:
+		m, err := c.compileLexical(e.Expr)
+		if err != nil {
+			return nil, err
+		}
+
+		return repeatLexical(e.Op, m), nil
+	case *Predicate:
+		m, err := c.compileLexical(e.Expr)
+		if err != nil {
+			return nil, err
+		}
+
+		not := e.Not
+
+		return func(t *parser.Tokeniser) bool {
+			s := t.State()
+			ok := m(t)
+
+			s.Reset()
+
+			return ok != not
+		}, nil
+	case *Capture:
+		return nil, &Error{Position: e.Position, Err: ErrLexicalCapture}
+	case *Reference:
+		if ruleKind(e.Name) == kindPhrase {
+			return nil, &Error{Position: e.Position, Err: fmt.Errorf("%w: %s", ErrPhraseInLexical, e.Name)}
+		}
+
+		m, ok := c.lexical[e.Name]
+		if !ok {
+			return nil, &Error{Position: e.Position, Err: fmt.Errorf("%w: %s", ErrUndefinedRule, e.Name)}
+		}
+
+		return func(t *parser.Tokeniser) bool {
+			return (*m)(t)
+		}, nil
+	case *Literal:
+		value := e.Value
+
+		return func(t *parser.Tokeniser) bool {
+			s := t.State()
+
+			if t.AcceptString(value, false) == len(value) {
+				return true
+			}
+
+			s.Reset()
+
+			return false
+		}, nil
+	case *Class:
+		class := e
+
+		return func(t *parser.Tokeniser) bool {
+			if r := t.Peek(); r != -1 && class.contains(r) {
+				t.Next()
+
+				return true
+			}
+
+			return false
+		}, nil
+	case *Any:
+		return func(t *parser.Tokeniser) bool {
+			if t.Peek() == -1 {
+				return false
+			}
+
+			t.Next()
+
+			return true
+		}, nil
+	}
+
+	return nil, &Error{Position: e.Pos(), Err: ErrUnknownExpression}
+}
+
+func (c *compiler) compileLexicals(es []Expr) ([]matcher, error) {
+	ms := make([]matcher, len(es))
+
+	for n, e := range es {
+		m, err := c.compileLexical(e)
+		if err != nil {
+			return nil, err
+		}
+
+		ms[n] = m
+	}
+
+	return ms, nil
+}
+
+func repeatLexical(op byte, m matcher) matcher {
+	many := func(t *parser.Tokeniser) bool {
+		for {
+			l := t.Len()
+
+			if !m(t) || t.Len() == l {
+				return true
+			}
+		}
+	}
+
+	switch op {
+	case '?':
+		return func(t *parser.Tokeniser) bool {
+			m(t)
+
+			return true
+		}
+	case '+':
+		return func(t *parser.Tokeniser) bool {
+			return m(t) && many(t)
+		}
+	}
+
+	return many
+}
+
+func (c *Class) contains(r rune) bool {
+	for _, rng := range c.Ranges {
+		if r >= rng.Low && r <= rng.High {
+			return !c.Negated
+		}
+	}
+
+	return c.Negated
+}
+
+func (c *compiler) terminal(r combinator.Rule[parser.Token]) combinator.Rule[children] {
+	t := tokenChild(r)
+
+	if c.skip == nil {
+		return t
+	}
+
+	return combinator.Map(combinator.Seq(c.skip, t), flatten)
+}
+
+func (c *compiler) compilePhrase(e Expr) (combinator.Rule[children], error) {
+	switch e := e.(type) {
+	case *Choice:
+		rs, err := c.compilePhrases(e.Alternatives)
+		if err != nil {
+			return nil, err
+		}
+
+		return combinator.Choice(rs...), nil
+	case *Sequence:
+		rs, err := c.compilePhrases(e.Items)
+		if err != nil {
+			return nil, err
+		}
+
+		return combinator.Map(combinator.Seq(rs...), flatten), nil
+	case *Repeat:
+		r, err := c.compilePhrase(e.Expr)
+		if err != nil {
+			return nil, err
+		}
+
+		switch e.Op {
+		case '?':
+			return combinator.Optional(r), nil
+		case '+':
+			return combinator.Map(combinator.Many1(r), flatten), nil
+		}
+
+		return combinator.Map(combinator.Many(r), flatten), nil
+	case *Predicate:
+		r, err := c.compilePhrase(e.Expr)
+		if err != nil {
+			return nil, err
+		}
+
+		if e.Not {
+			return combinator.Map(combinator.Not(r), empty), nil
+		}
+
+		return combinator.Map(combinator.Lookahead(r), empty), nil
+	case *Capture:
+		typ := c.addPhraseType(e.Label)
+
+		r, err := c.compilePhrase(e.Expr)
+		if err != nil {
+			return nil, err
+		}
+
+		return node(typ, r), nil
+	case *Reference:
+		switch ruleKind(e.Name) {
+		case kindFragment:
+			return nil, &Error{Position: e.Position, Err: fmt.Errorf("%w: %s", ErrFragmentInPhrase, e.Name)}
+		case kindToken:
+			typ, ok := c.TokenTypes[e.Name]
+			if !ok {
+				return nil, &Error{Position: e.Position, Err: fmt.Errorf("%w: %s", ErrUndefinedRule, e.Name)}
+			} else if c.skipped[typ] {
+				return nil, &Error{Position: e.Position, Err: fmt.Errorf("%w: %s", ErrSkippedInPhrase, e.Name)}
+			}
+
+			return c.terminal(combinator.Token(typ)), nil
+		}
+
+		r, ok := c.phrase[e.Name]
+		if !ok {
+			return nil, &Error{Position: e.Position, Err: fmt.Errorf("%w: %s", ErrUndefinedRule, e.Name)}
+		}
+
+		return func(in *combinator.Input) (children, bool) {
+			return (*r)(in)
+		}, nil
+	case *Literal:
+		value := e.Value
+
+		return c.terminal(combinator.Match(strconv.Quote(value), func(tk parser.Token) bool {
+			return tk.Data == value && !c.skipped[tk.Type]
+		})), nil
+	case *Class:
+		return nil, &Error{Position: e.Position, Err: ErrClassInPhrase}
+	case *Any:
+		return c.terminal(combinator.Match("any token", func(tk parser.Token) bool {
+			return !c.skipped[tk.Type]
+		})), nil
+	}
+
+	return nil, &Error{Position: e.Pos(), Err: ErrUnknownExpression}
+}
+
+func (c *compiler) compilePhrases(es []Expr) ([]combinator.Rule[children], error) {
+	rs := make([]combinator.Rule[children], len(es))
+
+	for n, e := range es {
+		r, err := c.compilePhrase(e)
+		if err != nil {
+			return nil, err
+		}
+
+		rs[n] = r
+	}
+
+	return rs, nil
+}
+
+// checkLeftRecursion returns an error for the first rule, in definition order,
+// that can reach itself without consuming any input.
+func checkLeftRecursion(rules []*Rule) error {
+	nullable := make(map[string]bool)
+
+	for changed := true; changed; {
+		changed = false
+
+		for _, r := range rules {
+			if !nullable[r.Name] && isNullable(ruleKind(r.Name) == kindPhrase, r.Expr, nullable) {
+				nullable[r.Name] = true
+				changed = true
+			}
+		}
+	}
+
+	left := make(map[string][]string)
+
+	for _, r := range rules {
+		left[r.Name] = leftRefs(ruleKind(r.Name) == kindPhrase, r.Expr, nullable)
+	}
+
+	for _, r := range rules {
+		if path := leftPath(r.Name, r.Name, left, make(map[string]bool)); path != nil {
+			return &Error{Position: r.Position, Err: fmt.Errorf("%w: %s -> %s", ErrLeftRecursion, r.Name, strings.Join(path, " -> "))}
+		}
+	}
+
+	return nil
+}
+
+// isNullable reports whether the expression can match without consuming any
+// input.
+//
+// In phrase rules, references to token rules and literals match Tokens, which
+// are never empty.
+func isNullable(phrase bool, e Expr, nullable map[string]bool) bool {
+	switch e := e.(type) {
+	case *Choice:
+		for _, a := range e.Alternatives {
+			if isNullable(phrase, a, nullable) {
+				return true
+			}
+		}
+	case *Sequence:
+		for _, i := range e.Items {
+			if !isNullable(phrase, i, nullable) {
+				return false
+			}
+		}
+
+		return true
+	case *Repeat:
+		return e.Op != '+' || isNullable(phrase, e.Expr, nullable)
+	case *Predicate:
+		return true
+	case *Capture:
+		return isNullable(phrase, e.Expr, nullable)
+	case *Reference:
+		return (!phrase || ruleKind(e.Name) == kindPhrase) && nullable[e.Name]
+	case *Literal:
+		return !phrase && e.Value == ""
+	}
+
+	return false
+}
+
+// leftRefs returns the names of the rules that the expression can reference
+// before consuming any input.
+func leftRefs(phrase bool, e Expr, nullable map[string]bool) []string {
+	switch e := e.(type) {
+	case *Choice:
+		var refs []string
+
+		for _, a := range e.Alternatives {
+			refs = append(refs, leftRefs(phrase, a, nullable)...)
+		}
+
+		return refs
+	case *Sequence:
+		var refs []string
+
+		for _, i := range e.Items {
+			refs = append(refs, leftRefs(phrase, i, nullable)...)
+
+			if !isNullable(phrase, i, nullable) {
+				break
+			}
+		}
+
+		return refs
+	case *Repeat:
+		return leftRefs(phrase, e.Expr, nullable)
+	case *Predicate:
+		return leftRefs(phrase, e.Expr, nullable)
+	case *Capture:
+		return leftRefs(phrase, e.Expr, nullable)
+	case *Reference:
+		if !phrase || ruleKind(e.Name) == kindPhrase {
+			return []string{e.Name}
+		}
+	}
+
+	return nil
+}
+
+// leftPath returns the path of rule names from the given rule to the target
+// rule, if there is one.
+func leftPath(target, from string, left map[string][]string, seen map[string]bool) []string {
+	for _, name := range left[from] {
+		if name == target {
+			return []string{name}
+		} else if seen[name] {
+			continue
+		}
+
+		seen[name] = true
+
+		if path := leftPath(target, name, left, seen); path != nil {
+			return append([]string{name}, path...)
+		}
+	}
+
+	return nil
+}
+
+// Tokenise is a TokenFunc that tries each of the token rules, in the order
+// they were defined, returning a Token for the first to match.
+func (c *Compiled) Tokenise(t *parser.Tokeniser) (parser.Token, parser.TokenFunc) {
+	if t.Peek() == -1 {
+		return t.Done()
+	}
+
+	for _, r := range c.tokenRules {
+		if r.match(t) && t.Len() > 0 {
+			return t.Return(r.typ, c.Tokenise)
+		}
+
+		t.Reset()
+	}
+
+	return t.ReturnError(fmt.Errorf("%w: %q", ErrNoTokenMatch, t.Peek()))
+}
+
+// Parser creates a new Parser that reads from the given Tokeniser, using the
+// Tokenise TokenFunc and, if the Grammar has phrase rules, a PhraseFunc that
+// matches the first phrase rule.
+//
+// Each Phrase has the type of the first phrase rule, and its Tree field is set
+// to a tree in which each matched phrase rule and capture is a Node. Skipped
+// Tokens are included in the tree, immediately before the next Token, or at
+// the end of the Phrase. Input containing only skipped Tokens results in a
+// PhraseDone Phrase.
+func (c *Compiled) Parser(t parser.Tokeniser) *parser.Parser {
+	p := parser.New(t)
+
+	p.TokeniserState(c.Tokenise)
+
+	if c.start != nil {
+		in := combinator.NewInput(&p)
+		in.Names = c.Names
+
+		p.PhraserState(c.phraseFunc(in))
+	}
+
+	return &p
+}
+
+// phraseFunc returns a PhraseFunc that matches the start rule, or returns
+// Parser.Done when only skipped Tokens remain.
+func (c *Compiled) phraseFunc(in *combinator.Input) parser.PhraseFunc {
+	start := combinator.PhraseFunc(in, c.startType, c.start)
+
+	if c.end == nil {
+		return start
+	}
+
+	var pf parser.PhraseFunc
+
+	pf = func(p *parser.Parser) (parser.Phrase, parser.PhraseFunc) {
+		if _, ok := c.end(in); ok {
+			return p.Done()
+		}
+
+		ph, next := start(p)
+		if ph.Type >= 0 {
+			next = pf
+		}
+
+		return ph, next
+	}
+
+	return pf
+}
+
+// Errors.
+var (
+	ErrDuplicateRule     = errors.New("duplicate rule")
+	ErrInvalidSkip       = errors.New("only token rules can be skipped")
+	ErrUndefinedRule     = errors.New("undefined rule")
+	ErrLexicalCapture    = errors.New("captures are only allowed in phrase rules")
+	ErrPhraseInLexical   = errors.New("phrase rule referenced from token rule")
+	ErrFragmentInPhrase  = errors.New("fragment rule referenced from phrase rule")
+	ErrSkippedInPhrase   = errors.New("skipped token rule referenced from phrase rule")
+	ErrClassInPhrase     = errors.New("character classes are only allowed in token rules")
+	ErrUnknownExpression = errors.New("unknown expression")
+	ErrNoTokenMatch      = errors.New("no token rule matches")
+	ErrLeftRecursion     = errors.New("left recursion")
+)
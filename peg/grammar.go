@@ -0,0 +1,629 @@
+// Package peg loads grammars written in a PEG (Parsing Expression Grammar) text
+// format, compiling them into TokenFuncs and PhraseFuncs.
+//
+// A grammar is a list of rules, each of the form:
+//
+//	Name <- Expression
+//
+// The case of the first character of the name determines the type of the
+// rule:
+//
+//   - Names starting with an uppercase letter are token rules, which match
+//     characters and produce a Token.
+//   - Names starting with an underscore are fragment rules, which match
+//     characters but only for use by other token and fragment rules.
+//   - Names starting with a lowercase letter are phrase rules, which match
+//     Tokens; the first phrase rule is used to produce each Phrase.
+//
+// A token rule defined with '<~' instead of '<-' is a skipped token rule; its
+// Tokens, such as whitespace and comments, are skipped over by phrase rules.
+//
+// Expressions are composed of the following, in decreasing order of
+// precedence:
+//
+//	"abc" 'abc'  Literal; in phrase rules matches a Token with the given data
+//	[a-z_]       Character class, which can be negated with a leading '^'
+//	.            Any character or, in phrase rules, any Token
+//	Name         Reference to another rule
+//	( e )        Grouping
+//	label:e      Capture, in phrase rules, of the Tokens matched by e into a
+//	             sub-Node of the Phrase tree
+//	e? e* e+     Optional, zero-or-more, one-or-more
+//	&e !e        And and Not predicates, which do not consume any input
+//	e1 e2        Sequence
+//	e1 / e2      Ordered choice
+//
+// Comments start with a '#' and run to the end of the line.
+package peg // import "vimagination.zapto.org/parser/peg"
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"vimagination.zapto.org/parser"
+)
+
+// Position represents a line and column, both 1-indexed, within the grammar
+// source. Columns are counted in runes.
+type Position struct {
+	Line, Column int
+}
+
+func (p Position) advance(data string) Position {
+	if n := strings.LastIndexByte(data, '\n'); n >= 0 {
+		p.Line += strings.Count(data, "\n")
+		p.Column = 1 + utf8.RuneCountInString(data[n+1:])
+	} else {
+		p.Column += utf8.RuneCountInString(data)
+	}
+
+	return p
+}
+
+// String formats the Position as line:column.
+func (p Position) String() string {
+	return strconv.Itoa(p.Line) + ":" + strconv.Itoa(p.Column)
+}
+
+// Grammar is the parsed form of a PEG grammar.
+type Grammar struct {
+	Rules []*Rule
+}
+
+// Rule is a single named rule of a Grammar.
+type Rule struct {
+	Position
+	Name string
+	Skip bool
+	Expr Expr
+}
+
+// Expr is one of Choice, Sequence, Repeat, Predicate, Capture, Reference,
+// Literal, Class or Any.
+type Expr interface {
+	Pos() Position
+}
+
+// Pos returns the Position.
+func (p Position) Pos() Position {
+	return p
+}
+
+// Choice is an ordered choice between alternative expressions.
+type Choice struct {
+	Position
+	Alternatives []Expr
+}
+
+// Sequence is a list of expressions that must all match, in order.
+type Sequence struct {
+	Position
+	Items []Expr
+}
+
+// Repeat is an expression that is optional ('?'), repeated zero or more times
+// ('*') or repeated one or more times ('+').
+type Repeat struct {
+	Position
+	Op   byte
+	Expr Expr
+}
+
+// Predicate is an expression that is matched without consuming input; if Not
+// is true, the Predicate succeeds only when the expression does not match.
+type Predicate struct {
+	Position
+	Not  bool
+	Expr Expr
+}
+
+// Capture collects the Tokens matched by an expression into a labelled Node.
+type Capture struct {
+	Position
+	Label string
+	Expr  Expr
+}
+
+// Reference is a reference to another Rule, by name.
+type Reference struct {
+	Position
+	Name string
+}
+
+// Literal is a literal string.
+type Literal struct {
+	Position
+	Value string
+}
+
+// Range is an inclusive range of runes.
+type Range struct {
+	Low, High rune
+}
+
+// Class is a character class, matching any rune within its Ranges or, when
+// Negated, any rune not within its Ranges.
+type Class struct {
+	Position
+	Negated bool
+	Ranges  []Range
+}
+
+// Any matches any single character or Token.
+type Any struct {
+	Position
+}
+
+const (
+	tokenWhitespace parser.TokenType = iota
+	tokenComment
+	tokenIdentifier
+	tokenArrow
+	tokenPunctuator
+	tokenLiteral
+	tokenClass
+)
+
+const (
+	letters        = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz_"
+	identifierChar = letters + "0123456789"
+)
+
+func lex(t *parser.Tokeniser) (parser.Token, parser.TokenFunc) {
+	switch {
+	case t.Peek() == -1:
+		return t.Done()
+	case t.Accept(" \t\r\n"):
+		t.AcceptRun(" \t\r\n")
+
+		return t.Return(tokenWhitespace, lex)
+	case t.Accept("#"):
+		t.ExceptRun("\n")
+
+		return t.Return(tokenComment, lex)
+	case t.Accept("<"):
+		if !t.Accept("-~") {
+			return t.ReturnError(ErrInvalidArrow)
+		}
+
+		return t.Return(tokenArrow, lex)
+	case t.Accept("/&!?*+().:"):
+		return t.Return(tokenPunctuator, lex)
+	case t.Peek() == '"', t.Peek() == '\'':
+		return lexQuoted(t, t.Next())
+	case t.Accept("["):
+		return lexClass(t)
+	case t.Accept(letters):
+		t.AcceptRun(identifierChar)
+
+		return t.Return(tokenIdentifier, lex)
+	}
+
+	return t.ReturnError(ErrInvalidCharacter)
+}
+
+func lexQuoted(t *parser.Tokeniser, quote rune) (parser.Token, parser.TokenFunc) {
+	for {
+		switch t.ExceptRun(string(quote) + "\\\n") {
+		case '\\':
+			t.Next()
+
+			if t.Next() == -1 {
+				return t.ReturnError(ErrUnterminatedLiteral)
+			}
+		case quote:
+			t.Next()
+
+			return t.Return(tokenLiteral, lex)
+		default:
+			return t.ReturnError(ErrUnterminatedLiteral)
+		}
+	}
+}
+
+func lexClass(t *parser.Tokeniser) (parser.Token, parser.TokenFunc) {
+	for {
+		switch t.ExceptRun("]\\\n") {
+		case '\\':
+			t.Next()
+
+			if t.Next() == -1 {
+				return t.ReturnError(ErrUnterminatedClass)
+			}
+		case ']':
+			t.Next()
+
+			return t.Return(tokenClass, lex)
+		default:
+			return t.ReturnError(ErrUnterminatedClass)
+		}
+	}
+}
+
+type token struct {
+	parser.Token
+	Position
+}
+
+type grammarParser struct {
+	tokens []token
+	pos    int
+}
+
+// Parse parses the grammar source into a Grammar.
+//
+// Errors will be of type *Error, giving the position of the error.
+func Parse(src string) (*Grammar, error) {
+	tk := parser.NewStringTokeniser(src)
+	pos := Position{Line: 1, Column: 1}
+
+	tk.TokeniserState(lex)
+
+	var g grammarParser
+
+	for t := range tk.Iter {
+		switch t.Type {
+		case parser.TokenError:
+			return nil, &Error{Position: pos, Err: tk.Err}
+		case tokenWhitespace, tokenComment:
+		default:
+			g.tokens = append(g.tokens, token{Token: t, Position: pos})
+		}
+
+		pos = pos.advance(t.Data)
+	}
+
+	return g.grammar()
+}
+
+func (g *grammarParser) peek() token {
+	return g.tokens[g.pos]
+}
+
+func (g *grammarParser) next() token {
+	t := g.tokens[g.pos]
+
+	if t.Type != parser.TokenDone {
+		g.pos++
+	}
+
+	return t
+}
+
+func (g *grammarParser) isPunctuator(c string) bool {
+	t := g.peek()
+
+	return t.Type == tokenPunctuator && t.Data == c
+}
+
+func (g *grammarParser) acceptPunctuator(c string) bool {
+	if g.isPunctuator(c) {
+		g.next()
+
+		return true
+	}
+
+	return false
+}
+
+func (g *grammarParser) isRuleStart() bool {
+	return g.peek().Type == tokenIdentifier && g.tokens[g.pos+1].Type == tokenArrow
+}
+
+func (g *grammarParser) grammar() (*Grammar, error) {
+	var gr Grammar
+
+	for g.peek().Type != parser.TokenDone {
+		r, err := g.rule()
+		if err != nil {
+			return nil, err
+		}
+
+		gr.Rules = append(gr.Rules, r)
+	}
+
+	return &gr, nil
+}
+
+func (g *grammarParser) rule() (*Rule, error) {
+	if !g.isRuleStart() {
+		return nil, g.unexpected(ErrExpectedRule)
+	}
+
+	name := g.next()
+	arrow := g.next()
+
+	expr, err := g.choice()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Rule{
+		Position: name.Position,
+		Name:     name.Data,
+		Skip:     arrow.Data == "<~",
+		Expr:     expr,
+	}, nil
+}
+
+func (g *grammarParser) choice() (Expr, error) {
+	pos := g.peek().Position
+
+	first, err := g.sequence()
+	if err != nil {
+		return nil, err
+	}
+
+	if !g.isPunctuator("/") {
+		return first, nil
+	}
+
+	c := &Choice{Position: pos, Alternatives: []Expr{first}}
+
+	for g.acceptPunctuator("/") {
+		alt, err := g.sequence()
+		if err != nil {
+			return nil, err
+		}
+
+		c.Alternatives = append(c.Alternatives, alt)
+	}
+
+	return c, nil
+}
+
+func (g *grammarParser) sequence() (Expr, error) {
+	s := &Sequence{Position: g.peek().Position}
+
+	for {
+		switch t := g.peek(); {
+		case t.Type == parser.TokenDone, g.isPunctuator("/"), g.isPunctuator(")"), g.isRuleStart():
+			switch len(s.Items) {
+			case 0:
+				return nil, g.unexpected(ErrExpectedExpression)
+			case 1:
+				return s.Items[0], nil
+			}
+
+			return s, nil
+		}
+
+		e, err := g.prefix()
+		if err != nil {
+			return nil, err
+		}
+
+		s.Items = append(s.Items, e)
+	}
+}
+
+func (g *grammarParser) prefix() (Expr, error) {
+	pos := g.peek().Position
+
+	if g.isPunctuator("&") || g.isPunctuator("!") {
+		not := g.next().Data == "!"
+
+		e, err := g.suffix()
+		if err != nil {
+			return nil, err
+		}
+
+		return &Predicate{Position: pos, Not: not, Expr: e}, nil
+	}
+
+	return g.suffix()
+}
+
+func (g *grammarParser) suffix() (Expr, error) {
+	pos := g.peek().Position
+
+	e, err := g.primary()
+	if err != nil {
+		return nil, err
+	}
+
+	for g.isPunctuator("?") || g.isPunctuator("*") || g.isPunctuator("+") {
+		e = &Repeat{Position: pos, Op: g.next().Data[0], Expr: e}
+	}
+
+	return e, nil
+}
+
+func (g *grammarParser) primary() (Expr, error) {
+	t := g.peek()
+
+	switch t.Type {
+	case tokenIdentifier:
+		g.next()
+
+		if g.acceptPunctuator(":") {
+			e, err := g.primary()
+			if err != nil {
+				return nil, err
+			}
+
+			return &Capture{Position: t.Position, Label: t.Data, Expr: e}, nil
+		}
+
+		return &Reference{Position: t.Position, Name: t.Data}, nil
+	case tokenLiteral:
+		g.next()
+
+		value, err := unescape(t.Data[1 : len(t.Data)-1])
+		if err != nil {
+			return nil, &Error{Position: t.Position, Err: err}
+		}
+
+		return &Literal{Position: t.Position, Value: value}, nil
+	case tokenClass:
+		g.next()
+
+		return class(t)
+	case tokenPunctuator:
+		switch t.Data {
+		case ".":
+			g.next()
+
+			return &Any{Position: t.Position}, nil
+		case "(":
+			g.next()
+
+			e, err := g.choice()
+			if err != nil {
+				return nil, err
+			}
+
+			if !g.acceptPunctuator(")") {
+				return nil, g.unexpected(ErrMissingCloseParen)
+			}
+
+			return e, nil
+		}
+	}
+
+	return nil, g.unexpected(ErrExpectedExpression)
+}
+
+func (g *grammarParser) unexpected(err error) error {
+	t := g.peek()
+
+	if t.Type == parser.TokenDone {
+		return &Error{Position: t.Position, Err: fmt.Errorf("%w, got end of grammar", err)}
+	}
+
+	return &Error{Position: t.Position, Err: fmt.Errorf("%w, got %q", err, t.Data)}
+}
+
+func class(t token) (*Class, error) {
+	c := &Class{Position: t.Position}
+	runes, err := unescapeRunes(t.Data[1 : len(t.Data)-1])
+	if err != nil {
+		return nil, &Error{Position: t.Position, Err: err}
+	}
+
+	if len(runes) > 0 && runes[0].r == '^' && !runes[0].escaped {
+		c.Negated = true
+		runes = runes[1:]
+	}
+
+	for n := 0; n < len(runes); n++ {
+		r := Range{Low: runes[n].r, High: runes[n].r}
+
+		if n+2 < len(runes) && runes[n+1].r == '-' && !runes[n+1].escaped {
+			r.High = runes[n+2].r
+			n += 2
+
+			if r.High < r.Low {
+				return nil, &Error{Position: t.Position, Err: fmt.Errorf("%w: %q-%q", ErrInvalidRange, r.Low, r.High)}
+			}
+		}
+
+		c.Ranges = append(c.Ranges, r)
+	}
+
+	return c, nil
+}
+
+type char struct {
+	r       rune
+	escaped bool
+}
+
+func unescape(str string) (string, error) {
+	runes, err := unescapeRunes(str)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+
+	for _, r := range runes {
+		sb.WriteRune(r.r)
+	}
+
+	return sb.String(), nil
+}
+
+func unescapeRunes(str string) ([]char, error) {
+	var chars []char
+
+	for len(str) > 0 {
+		r, s := utf8.DecodeRuneInString(str)
+		str = str[s:]
+
+		if r != '\\' {
+			chars = append(chars, char{r: r})
+
+			continue
+		}
+
+		r, s = utf8.DecodeRuneInString(str)
+		str = str[s:]
+
+		switch r {
+		case 'n':
+			r = '\n'
+		case 'r':
+			r = '\r'
+		case 't':
+			r = '\t'
+		case '\\', '\'', '"', '[', ']', '-', '^':
+		case 'x', 'u':
+			l := 2
+
+			if r == 'u' {
+				l = 4
+			}
+
+			if len(str) < l {
+				return nil, ErrInvalidEscape
+			}
+
+			n, err := strconv.ParseUint(str[:l], 16, 32)
+			if err != nil {
+				return nil, ErrInvalidEscape
+			}
+
+			r = rune(n)
+			str = str[l:]
+		default:
+			return nil, ErrInvalidEscape
+		}
+
+		chars = append(chars, char{r: r, escaped: true})
+	}
+
+	return chars, nil
+}
+
+// Error is an error in a grammar, giving the position at which it occurred.
+type Error struct {
+	Position
+	Err error
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	return e.Position.String() + ": " + e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Errors.
+var (
+	ErrInvalidArrow        = errors.New("invalid arrow, expecting '<-' or '<~'")
+	ErrInvalidCharacter    = errors.New("invalid character")
+	ErrUnterminatedLiteral = errors.New("unterminated literal")
+	ErrUnterminatedClass   = errors.New("unterminated character class")
+	ErrExpectedRule        = errors.New("expected rule definition")
+	ErrExpectedExpression  = errors.New("expected expression")
+	ErrMissingCloseParen   = errors.New("expected ')'")
+	ErrInvalidEscape       = errors.New("invalid escape sequence")
+	ErrInvalidRange        = errors.New("invalid character range")
+)
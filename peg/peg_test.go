@@ -0,0 +1,320 @@
+package peg
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"vimagination.zapto.org/parser"
+	"vimagination.zapto.org/parser/parsertest"
+)
+
+const calc = `
+# A simple calculator.
+program    <- statement*
+statement  <- Ident "=" expression ";" / expression ";"
+expression <- term (op:("+" / "-") term)*
+term       <- Number / Ident / "(" expression ")"
+
+Whitespace <~ [ \t\n]+
+Comment    <~ "#" [^\n]*
+Number     <- _digit+ ("." _digit+)?
+Ident      <- [a-zA-Z_] ([a-zA-Z_] / _digit)*
+Punctuator <- [=;+\-()]
+_digit     <- [0-9]
+`
+
+func TestParseErrors(t *testing.T) {
+	for n, test := range [...]struct {
+		Input string
+		Err   error
+		Pos   Position
+	}{
+		{
+			Input: "a <- 'b",
+			Err:   ErrUnterminatedLiteral,
+			Pos:   Position{Line: 1, Column: 6},
+		},
+		{
+			Input: "a <- b\nc <- [a",
+			Err:   ErrUnterminatedClass,
+			Pos:   Position{Line: 2, Column: 6},
+		},
+		{
+			Input: "a <- b\n  / \nc <- d",
+			Err:   ErrExpectedExpression,
+			Pos:   Position{Line: 3, Column: 1},
+		},
+		{
+			Input: "a <- (b c",
+			Err:   ErrMissingCloseParen,
+			Pos:   Position{Line: 1, Column: 10},
+		},
+		{
+			Input: "a < b",
+			Err:   ErrInvalidArrow,
+			Pos:   Position{Line: 1, Column: 3},
+		},
+		{
+			Input: "a <- [z-a]",
+			Err:   ErrInvalidRange,
+			Pos:   Position{Line: 1, Column: 6},
+		},
+		{
+			Input: "<- a",
+			Err:   ErrExpectedRule,
+			Pos:   Position{Line: 1, Column: 1},
+		},
+	} {
+		var e *Error
+
+		if _, err := Parse(test.Input); !errors.Is(err, test.Err) {
+			t.Errorf("test %d: expecting error %v, got %v", n+1, test.Err, err)
+		} else if !errors.As(err, &e) {
+			t.Errorf("test %d: expecting *Error, got %T", n+1, err)
+		} else if e.Position != test.Pos {
+			t.Errorf("test %d: expecting error at %s, got %s", n+1, test.Pos, e.Position)
+		}
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	for n, test := range [...]struct {
+		Input string
+		Err   error
+		Pos   Position
+	}{
+		{
+			Input: "A <- 'a'\nA <- 'b'",
+			Err:   ErrDuplicateRule,
+			Pos:   Position{Line: 2, Column: 1},
+		},
+		{
+			Input: "a <- B",
+			Err:   ErrUndefinedRule,
+			Pos:   Position{Line: 1, Column: 6},
+		},
+		{
+			Input: "a <~ 'b'",
+			Err:   ErrInvalidSkip,
+			Pos:   Position{Line: 1, Column: 1},
+		},
+		{
+			Input: "A <- 'a' b\nb <- A",
+			Err:   ErrPhraseInLexical,
+			Pos:   Position{Line: 1, Column: 10},
+		},
+		{
+			Input: "a <- _b\n_b <- 'b'",
+			Err:   ErrFragmentInPhrase,
+			Pos:   Position{Line: 1, Column: 6},
+		},
+		{
+			Input: "a <- B\nB <~ ' '",
+			Err:   ErrSkippedInPhrase,
+			Pos:   Position{Line: 1, Column: 6},
+		},
+		{
+			Input: "a <- [a-z]",
+			Err:   ErrClassInPhrase,
+			Pos:   Position{Line: 1, Column: 6},
+		},
+		{
+			Input: "A <- x:'a'",
+			Err:   ErrLexicalCapture,
+			Pos:   Position{Line: 1, Column: 6},
+		},
+		{
+			Input: "expr <- expr Plus Num / Num\nPlus <- '+'\nNum <- [0-9]+",
+			Err:   ErrLeftRecursion,
+			Pos:   Position{Line: 1, Column: 1},
+		},
+		{
+			Input: "X <- 'x'\na <- b X / X\nb <- c? d\nc <- X\nd <- !X a",
+			Err:   ErrLeftRecursion,
+			Pos:   Position{Line: 2, Column: 1},
+		},
+		{
+			Input: "B <- 'b'\nA <- A 'x'",
+			Err:   ErrLeftRecursion,
+			Pos:   Position{Line: 2, Column: 1},
+		},
+		{
+			Input: "A <- _b 'x'\n_b <- 'y'* ''  A",
+			Err:   ErrLeftRecursion,
+			Pos:   Position{Line: 1, Column: 1},
+		},
+	} {
+		var e *Error
+
+		if _, err := Compile(test.Input); !errors.Is(err, test.Err) {
+			t.Errorf("test %d: expecting error %v, got %v", n+1, test.Err, err)
+		} else if !errors.As(err, &e) {
+			t.Errorf("test %d: expecting *Error, got %T", n+1, err)
+		} else if e.Position != test.Pos {
+			t.Errorf("test %d: expecting error at %s, got %s", n+1, test.Pos, e.Position)
+		}
+	}
+}
+
+func TestCompileRecursion(t *testing.T) {
+	for n, input := range [...]string{
+		"a <- X a / X\nX <- 'x'",
+		"a <- '(' a ')' / X\nX <- [a-z()]",
+		"A <- 'x' A / 'y'",
+		"a <- b X\nb <- X a?\nX <- 'x'",
+	} {
+		if _, err := Compile(input); err != nil {
+			t.Errorf("test %d: unexpected error: %s", n+1, err)
+		}
+	}
+}
+
+func TestTokenise(t *testing.T) {
+	c, err := Compile(calc)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	var (
+		ws    = c.TokenTypes["Whitespace"]
+		num   = c.TokenTypes["Number"]
+		ident = c.TokenTypes["Ident"]
+		punc  = c.TokenTypes["Punctuator"]
+		cmt   = c.TokenTypes["Comment"]
+	)
+
+	parsertest.CheckTokens(t, c.Names, "a = 1.5 + b2; # done", c.Tokenise, []parser.Token{
+		{Type: ident, Data: "a"},
+		{Type: ws, Data: " "},
+		{Type: punc, Data: "="},
+		{Type: ws, Data: " "},
+		{Type: num, Data: "1.5"},
+		{Type: ws, Data: " "},
+		{Type: punc, Data: "+"},
+		{Type: ws, Data: " "},
+		{Type: ident, Data: "b2"},
+		{Type: punc, Data: ";"},
+		{Type: ws, Data: " "},
+		{Type: cmt, Data: "# done"},
+		{Type: parser.TokenDone, Data: ""},
+	})
+
+	tk := parser.NewStringTokeniser("a ?")
+
+	tk.TokeniserState(c.Tokenise)
+
+	tk.GetToken()
+	tk.GetToken()
+
+	if _, err := tk.GetToken(); !errors.Is(err, ErrNoTokenMatch) {
+		t.Errorf("expecting ErrNoTokenMatch, got %v", err)
+	}
+}
+
+func format(names *parser.Names, n *parser.Node) string {
+	var sb strings.Builder
+
+	sb.WriteString(names.Phrase(n.Type))
+	sb.WriteByte('(')
+
+	first := true
+
+	for _, child := range n.Children {
+		if child.Node == nil && names.Token(child.Token.Type) == "Whitespace" {
+			continue
+		}
+
+		if !first {
+			sb.WriteByte(' ')
+		}
+
+		first = false
+
+		if child.Node != nil {
+			sb.WriteString(format(names, child.Node))
+		} else {
+			fmt.Fprintf(&sb, "%q", child.Token.Data)
+		}
+	}
+
+	sb.WriteByte(')')
+
+	return sb.String()
+}
+
+func TestParser(t *testing.T) {
+	c, err := Compile(calc)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	p := c.Parser(parser.NewStringTokeniser("a = 1 + (b - 2);\n# comment\nc;\n"))
+
+	ph, err := p.GetPhrase()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	const expected = `program(statement("a" "=" expression(term("1") op("+") term("(" expression(term("b") op("-") term("2")) ")")) ";") statement(expression(term("# comment" "c")) ";"))`
+
+	if ph.Type != c.PhraseTypes["program"] {
+		t.Errorf("expecting phrase type %d, got %d", c.PhraseTypes["program"], ph.Type)
+	} else if got := format(c.Names, ph.Tree); got != expected {
+		t.Errorf("expecting tree:\n%s\ngot:\n%s", expected, got)
+	} else if len(ph.Data) != 22 {
+		t.Errorf("expecting 22 tokens, got %d", len(ph.Data))
+	} else if ph.Span != (parser.Span{Start: 0, End: 30}) || ph.Tree.Span != ph.Span {
+		t.Errorf("expecting phrase span {0 30}, got %v and %v", ph.Span, ph.Tree.Span)
+	} else if span := ph.Tree.Children[0].Span; span != (parser.Span{Start: 0, End: 16}) {
+		t.Errorf("expecting first statement span {0 16}, got %v", span)
+	} else if span := ph.Tree.Children[1].Span; span != (parser.Span{Start: 16, End: 29}) {
+		t.Errorf("expecting second statement span {16 29}, got %v", span)
+	} else if span := ph.Tree.Children[0].Node.Children[3].Node.Children[1].Span; span != (parser.Span{Start: 5, End: 7}) {
+		t.Errorf("expecting op span {5 7}, got %v", span)
+	}
+
+	if ph, err := p.GetPhrase(); !errors.Is(err, io.EOF) || ph.Type != parser.PhraseDone {
+		t.Errorf("expecting PhraseDone, got %v, %v", ph, err)
+	}
+
+	p = c.Parser(parser.NewStringTokeniser("a = ;"))
+
+	if _, err := p.GetPhrase(); err == nil || err.Error() != `expected Number, Ident or "(", got Punctuator ";" at offset 4` {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestParserSkippedOnly(t *testing.T) {
+	c, err := Compile("line <- Word+\nWord <- [a-z]+\nSpace <~ [ \\n]+\nComment <~ '#' [^\\n]*")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	for n, test := range [...]struct {
+		Input   string
+		Phrases int
+	}{
+		{Input: ""},
+		{Input: "   "},
+		{Input: "\n"},
+		{Input: "# comment\n  # another"},
+		{Input: " a b\n ", Phrases: 1},
+	} {
+		p := c.Parser(parser.NewStringTokeniser(test.Input))
+
+		for m := range test.Phrases {
+			if ph, err := p.GetPhrase(); err != nil {
+				t.Errorf("test %d.%d: unexpected error: %s", n+1, m+1, err)
+			} else if ph.Type != c.PhraseTypes["line"] {
+				t.Errorf("test %d.%d: expecting line phrase, got %d", n+1, m+1, ph.Type)
+			}
+		}
+
+		if ph, err := p.GetPhrase(); ph.Type != parser.PhraseDone || err != nil && !errors.Is(err, io.EOF) {
+			t.Errorf("test %d: expecting PhraseDone, got %v, %v", n+1, ph, err)
+		}
+	}
+}
@@ -11,10 +11,14 @@ import (
 // Negative values are reserved for this package.
 type PhraseType int
 
-// Constants PhraseError (-2) and PhraseDone (-1).
+// Constants PhraseSkipped (-3), PhraseError (-2) and PhraseDone (-1).
+//
+// PhraseSkipped Phrases contain the Tokens skipped while recovering from an
+// error; see Parser.Recover.
 const (
 	PhraseDone PhraseType = -1 - iota
 	PhraseError
+	PhraseSkipped
 )
 
 // PhraseFunc is the type that the worker types implement in order to be used
@@ -22,18 +26,54 @@ const (
 type PhraseFunc func(*Parser) (Phrase, PhraseFunc)
 
 // Phrase represents a collection of tokens that have meaning together.
+//
+// When the Parser is in tree mode, Tree will contain the root of the concrete
+// syntax tree built for the Phrase, and Data will contain all of the Tokens
+// of the tree, in order.
+//
+// Span covers the Tokens of the Phrase, starting at the end of the previous
+// Phrase, so that it includes any data skipped before the first Token.
 type Phrase struct {
 	Type PhraseType
 	Data []Token
+	Tree *Node
+	Span Span
+}
+
+// Span represents a range of bytes within the input, from Start (inclusive) to
+// End (exclusive).
+type Span struct {
+	Start, End int
 }
 
 // Parser is a type used to get tokens or phrases (collection of token) from an
 // input.
 type Parser struct {
 	Tokeniser
+
+	// Names, if set, is used to name TokenTypes in errors.
+	Names *Names
+
 	state       PhraseFunc
 	tokens      []Token
-	peekedToken bool
+	spans       []Span
+	trivia      []trivia
+	end         int
+	lastEnd     int
+	phraseStart int
+	pos         int
+	base        int
+	tree        bool
+	nodes       []*Node
+	expected    []expectation
+	expectedAt  int
+
+	triviaTypes []TokenType
+	held        heldToken
+
+	resume      PhraseFunc
+	sync        []TokenType
+	diagnostics []Diagnostic
 }
 
 // GetPhrase runs the state machine and retrieves a single Phrase and possibly
@@ -56,6 +96,10 @@ func (p *Parser) GetPhrase() (Phrase, error) {
 	ph, p.state = p.state(p)
 
 	if ph.Type == PhraseError {
+		if rph, ok := p.recover(); ok {
+			return rph, nil
+		}
+
 		if errors.Is(p.Err, io.EOF) {
 			p.Err = io.ErrUnexpectedEOF
 		}
@@ -83,9 +127,17 @@ func (p *Parser) GetToken() (Token, error) {
 
 // Iter yields each Phrase as it's returned, stopping after yielding a
 // PhraseDone or PhraseError Phrase.
+//
+// Stopping early stops any pipeline started with Pipeline.
 func (p *Parser) Iter(yield func(Phrase) bool) {
 	for {
-		if ph, _ := p.GetPhrase(); !yield(ph) || ph.Type == PhraseDone || ph.Type == PhraseError {
+		ph, _ := p.GetPhrase()
+
+		if !yield(ph) {
+			p.StopPipeline()
+
+			break
+		} else if ph.Type == PhraseDone || ph.Type == PhraseError {
 			break
 		}
 	}
@@ -97,26 +149,76 @@ func (p *Parser) PhraserState(pf PhraseFunc) {
 }
 
 func (p *Parser) get() Token {
-	if p.peekedToken {
-		p.peekedToken = false
+	if p.pos < len(p.tokens) {
+		p.pos++
 
-		return p.tokens[len(p.tokens)-1]
-	} else if len(p.tokens) > 0 && p.tokens[len(p.tokens)-1].Type < 0 {
+		return p.tokens[p.pos-1]
+	} else if p.finished() {
 		return p.tokens[len(p.tokens)-1]
 	}
 
+	tk := p.read()
+	p.pos++
+
+	return tk
+}
+
+func (p *Parser) read() Token {
+	if len(p.triviaTypes) > 0 {
+		return p.readLossless()
+	}
+
+	tk, span := p.pull()
+
+	p.push(tk, span, trivia{})
+
+	return tk
+}
+
+func (p *Parser) pull() (Token, Span) {
 	tk := p.Tokeniser.get()
+	end := p.Tokeniser.end
+	start := end
+
+	if tk.Type >= 0 {
+		start = max(end-len(tk.Data), p.end)
+	}
+
+	p.end = end
+
+	return tk, Span{Start: start, End: end}
+}
+
+func (p *Parser) push(tk Token, span Span, t trivia) {
 	p.tokens = append(p.tokens, tk)
+	p.spans = append(p.spans, span)
+	p.trivia = append(p.trivia, t)
+}
 
-	return tk
+func (p *Parser) finished() bool {
+	return len(p.tokens) > 0 && p.tokens[len(p.tokens)-1].Type < 0
 }
 
 func (p *Parser) backup() {
-	p.peekedToken = true
+	if p.pos > 0 {
+		p.pos--
+	}
+}
+
+// Backup moves the read position back by n Tokens, so that they will be read
+// again.
+//
+// Only Tokens read since the last call to Get can be backed up; if n is
+// greater than Len, the read position is moved back by Len Tokens.
+func (p *Parser) Backup(n int) {
+	p.pos -= min(max(n, 0), p.pos)
 }
 
 // Accept will accept a token with one of the given types, returning true if
 // one is read and false otherwise.
+//
+// When no token is accepted, the types are recorded as expected for use by
+// Unexpected.
 func (p *Parser) Accept(types ...TokenType) bool {
 	tk := p.get()
 
@@ -125,6 +227,7 @@ func (p *Parser) Accept(types ...TokenType) bool {
 	}
 
 	p.backup()
+	p.expectTypes(types)
 
 	return false
 }
@@ -142,32 +245,232 @@ func (p *Parser) Peek() Token {
 	return tk
 }
 
+// PeekN returns the Token n places after the next Token, without advancing
+// the read position, reading Tokens from the Tokeniser as necessary.
+//
+// PeekN(0) is equivalent to Peek. If the Tokeniser finishes, or errors, before
+// the requested Token, the TokenDone or TokenError Token is returned.
+func (p *Parser) PeekN(n int) Token {
+	n = p.pos + max(n, 0)
+
+	for n >= len(p.tokens) {
+		if p.finished() {
+			return p.tokens[len(p.tokens)-1]
+		}
+
+		p.read()
+	}
+
+	return p.tokens[n]
+}
+
 // Get retrieves a slice of the Tokens that have been read so far.
+//
+// Any Tokens that have been peeked, but not read, are kept to be read later.
 func (p *Parser) Get() []Token {
-	var toRet []Token
-
-	if p.peekedToken {
-		tk := p.tokens[len(p.tokens)-1]
-		toRet = slices.Clone(p.tokens[:len(p.tokens)-1])
-		p.tokens[0] = tk
-		p.tokens = p.tokens[:1]
-	} else {
-		toRet = slices.Clone(p.tokens)
-		p.tokens = p.tokens[:0]
+	if p.pos > 0 {
+		p.lastEnd = p.spans[p.pos-1].End
 	}
 
+	toRet := slices.Clone(p.tokens[:p.pos])
+	p.tokens = p.tokens[:copy(p.tokens, p.tokens[p.pos:])]
+	p.spans = p.spans[:copy(p.spans, p.spans[p.pos:])]
+	p.trivia = p.trivia[:copy(p.trivia, p.trivia[p.pos:])]
+	p.base += p.pos
+	p.pos = 0
+
 	return toRet
 }
 
-// Len returns how many tokens have been read.
-func (p *Parser) Len() int {
-	l := len(p.tokens)
+// Reset restores the read position to after the last Get() call (or init, if
+// Get() has not been called), so that the Tokens read since will be read again.
+//
+// To reset the byte stream of the underlying Tokeniser, use
+// p.Tokeniser.Reset().
+func (p *Parser) Reset() {
+	p.pos = 0
+}
+
+// State retrieves the current read position in the Token stream, allowing the
+// Parser to be returned to that point, for example to try an alternative
+// production after a failed one.
+//
+// The State remains valid as long as Get() has not been called after reading
+// past the position; Tokens already pulled from the Tokeniser are reused.
+//
+// In tree mode, the State also records the open Nodes, and the calls to
+// OpenNode and CloseNode made after it was retrieved do not invalidate it;
+// resetting it discards the Nodes opened since and returns the Tokens added to
+// the tree since to the Parser, so that they will be read again. The State is
+// invalidated by a call to Return.
+//
+// To retrieve the state of the underlying Tokeniser, use p.Tokeniser.State().
+func (p *Parser) State() State {
+	s := &parserState{
+		p:       p,
+		pos:     p.base + p.pos,
+		base:    p.base,
+		lastEnd: p.lastEnd,
+	}
+
+	for _, n := range p.nodes {
+		s.nodes = append(s.nodes, nodeState{node: n, children: len(n.Children), span: n.Span})
+	}
+
+	return s
+}
+
+type parserState struct {
+	p       *Parser
+	pos     int
+	base    int
+	lastEnd int
+	nodes   []nodeState
+}
+
+type nodeState struct {
+	node     *Node
+	children int
+	span     Span
+}
+
+func (s *parserState) Reset() bool {
+	if !s.p.tree {
+		return s.resetPos(0)
+	}
+
+	children, ok := s.added()
+	if !ok {
+		return false
+	}
+
+	var restored []Child
+
+	for _, c := range children {
+		restored = appendTokenChildren(restored, c)
+	}
+
+	if len(restored) > 0 && s.p.base-len(restored) != s.base {
+		return false
+	} else if !s.resetPos(len(restored)) {
+		return false
+	}
 
-	if p.peekedToken {
-		l--
+	s.p.nodes = s.p.nodes[:0]
+
+	for _, ns := range s.nodes {
+		ns.node.Children = ns.node.Children[:ns.children]
+		ns.node.Span = ns.span
+		s.p.nodes = append(s.p.nodes, ns.node)
+	}
+
+	if len(restored) > 0 {
+		s.p.restore(restored)
+		s.p.lastEnd = s.lastEnd
 	}
 
-	return l
+	return true
+}
+
+func (s *parserState) resetPos(restored int) bool {
+	pos := s.pos - s.p.base + restored
+
+	if pos < 0 || pos > len(s.p.tokens)+restored {
+		return false
+	}
+
+	s.p.pos = pos
+
+	return true
+}
+
+// added returns, in order, the children added to the tree since the State was
+// retrieved, returning false if the tree has since been returned.
+func (s *parserState) added() ([]Child, bool) {
+	open := 0
+
+	for open < len(s.nodes) && open < len(s.p.nodes) && s.p.nodes[open] == s.nodes[open].node {
+		open++
+	}
+
+	if open == 0 && len(s.nodes) > 0 {
+		return nil, false
+	}
+
+	var children []Child
+
+	for i := len(s.nodes) - 1; i >= 0; i-- {
+		cs := s.nodes[i].node.Children[s.nodes[i].children:]
+
+		if i < len(s.nodes)-1 && len(cs) > 0 && cs[0].Node == s.nodes[i+1].node {
+			cs = cs[1:]
+		}
+
+		children = append(children, cs...)
+	}
+
+	for _, n := range s.p.nodes[open:] {
+		children = append(children, n.Children...)
+	}
+
+	return children, true
+}
+
+func appendTokenChildren(children []Child, c Child) []Child {
+	if c.Node == nil {
+		return append(children, c)
+	}
+
+	for _, cc := range c.Node.Children {
+		children = appendTokenChildren(children, cc)
+	}
+
+	return children
+}
+
+// restore returns Tokens, previously removed by Get, to the front of the
+// buffer.
+func (p *Parser) restore(children []Child) {
+	tokens := make([]Token, len(children))
+	spans := make([]Span, len(children))
+	tr := make([]trivia, len(children))
+
+	for i, c := range children {
+		tokens[i] = c.Token
+		spans[i] = c.Span
+		tr[i].leading = c.Leading
+		tr[i].trailing = c.Trailing
+	}
+
+	p.tokens = append(tokens, p.tokens...)
+	p.spans = append(spans, p.spans...)
+	p.trivia = append(tr, p.trivia...)
+	p.base -= len(children)
+}
+
+// Offset returns the byte offset, within the input, of the next Token.
+//
+// The offset of a Token is determined by the amount of data returned by
+// Tokeniser.Get, and so includes any data skipped by the TokenFunc.
+func (p *Parser) Offset() int {
+	p.PeekN(0)
+
+	return p.spans[min(p.pos, len(p.spans)-1)].Start
+}
+
+// End returns the byte offset, within the input, of the end of the last Token
+// read.
+func (p *Parser) End() int {
+	if p.pos > 0 {
+		return p.spans[p.pos-1].End
+	}
+
+	return p.lastEnd
+}
+
+// Len returns how many tokens have been read.
+func (p *Parser) Len() int {
+	return p.pos
 }
 
 // AcceptRun will keep Accepting tokens as long as they match one of the
@@ -193,6 +496,9 @@ Loop:
 
 // AcceptToken will accept a token matching one of the ones provided exactly,
 // returning true if one is read and false otherwise.
+//
+// When no token is accepted, the tokens are recorded as expected for use by
+// Unexpected.
 func (p *Parser) AcceptToken(tokens ...Token) bool {
 	tk := p.get()
 
@@ -201,6 +507,7 @@ func (p *Parser) AcceptToken(tokens ...Token) bool {
 	}
 
 	p.backup()
+	p.expectTokens(tokens)
 
 	return false
 }
@@ -247,10 +554,23 @@ func (p *Parser) Return(typ PhraseType, fn PhraseFunc) (Phrase, PhraseFunc) {
 		fn = (*Parser).Done
 	}
 
-	return Phrase{
-		Type: typ,
-		Data: p.Get(),
-	}, fn
+	ph := Phrase{Type: typ}
+
+	if p.tree {
+		ph.Tree = p.buildTree(typ)
+		ph.Data = ph.Tree.Tokens()
+	} else {
+		ph.Data = p.Get()
+	}
+
+	ph.Span = Span{Start: p.phraseStart, End: max(p.lastEnd, p.phraseStart)}
+	p.phraseStart = ph.Span.End
+
+	if ph.Tree != nil {
+		ph.Tree.Span = ph.Span
+	}
+
+	return ph, fn
 }
 
 // ReturnError simplifies the handling of errors, setting the error and calling
@@ -263,12 +583,18 @@ func (p *Parser) ReturnError(err error) (Phrase, PhraseFunc) {
 
 // Done is a PhraseFunc that is used to indicate that there are no more phrases
 // to parse.
+//
+// In lossless mode, the first PhraseDone Phrase will have a Tree containing
+// any trivia that could not be attached to another Token, which only happens
+// when the input contains nothing else.
 func (p *Parser) Done() (Phrase, PhraseFunc) {
 	p.Err = io.EOF
+	p.nodes = p.nodes[:0]
 
 	return Phrase{
 		Type: PhraseDone,
 		Data: make([]Token, 0),
+		Tree: p.remainingTrivia(),
 	}, (*Parser).Done
 }
 
@@ -281,6 +607,8 @@ func (p *Parser) Error() (Phrase, PhraseFunc) {
 		p.Err = ErrUnknownError
 	}
 
+	p.nodes = p.nodes[:0]
+
 	return Phrase{
 		Type: PhraseError,
 		Data: []Token{
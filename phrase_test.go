@@ -0,0 +1,179 @@
+package parser
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParserPeekN(t *testing.T) {
+	p := New(NewStringTokeniser("a(b)c"))
+
+	p.TokeniserState(treeTokeniser)
+
+	for n, test := range [...]struct {
+		N        int
+		Expected Token
+	}{
+		{0, Token{Type: treeWord, Data: "a"}},
+		{2, Token{Type: treeWord, Data: "b"}},
+		{1, Token{Type: treeOpen, Data: "("}},
+		{4, Token{Type: treeWord, Data: "c"}},
+		{5, Token{Type: TokenDone, Data: ""}},
+		{10, Token{Type: TokenDone, Data: ""}},
+	} {
+		if tk := p.PeekN(test.N); tk != test.Expected {
+			t.Errorf("test %d: expecting token %v, got %v", n+1, test.Expected, tk)
+		} else if p.Len() != 0 {
+			t.Errorf("test %d: expecting Len 0, got %d", n+1, p.Len())
+		}
+	}
+
+	p.Next()
+	p.Next()
+
+	if tk := p.PeekN(1); tk != (Token{Type: treeClose, Data: ")"}) {
+		t.Errorf("test 7: expecting close token, got %v", tk)
+	} else if got := p.Get(); !reflect.DeepEqual(got, []Token{{Type: treeWord, Data: "a"}, {Type: treeOpen, Data: "("}}) {
+		t.Errorf("test 8: unexpected tokens: %v", got)
+	} else if tk := p.PeekN(2); tk != (Token{Type: treeWord, Data: "c"}) {
+		t.Errorf("test 9: expecting word token \"c\", got %v", tk)
+	}
+
+	p.AcceptRun(treeWord, treeClose)
+
+	if tk := p.PeekN(3); tk.Type != TokenDone {
+		t.Errorf("test 10: expecting TokenDone, got %v", tk)
+	} else if got := p.Get(); !reflect.DeepEqual(got, []Token{{Type: treeWord, Data: "b"}, {Type: treeClose, Data: ")"}, {Type: treeWord, Data: "c"}}) {
+		t.Errorf("test 11: unexpected tokens: %v", got)
+	} else if tk := p.Next(); tk.Type != TokenDone {
+		t.Errorf("test 12: expecting TokenDone, got %v", tk)
+	} else if tk := p.Next(); tk.Type != TokenDone {
+		t.Errorf("test 13: expecting TokenDone, got %v", tk)
+	} else if p.Len() != 1 {
+		t.Errorf("test 14: expecting Len 1, got %d", p.Len())
+	}
+}
+
+func TestParserBackup(t *testing.T) {
+	p := New(NewStringTokeniser("a(b)c"))
+
+	p.TokeniserState(treeTokeniser)
+
+	p.Next()
+	p.Next()
+	p.Next()
+	p.Backup(2)
+
+	if p.Len() != 1 {
+		t.Errorf("test 1: expecting Len 1, got %d", p.Len())
+	} else if tk := p.Next(); tk != (Token{Type: treeOpen, Data: "("}) {
+		t.Errorf("test 2: expecting open token, got %v", tk)
+	} else if got := p.Get(); !reflect.DeepEqual(got, []Token{{Type: treeWord, Data: "a"}, {Type: treeOpen, Data: "("}}) {
+		t.Errorf("test 3: unexpected tokens: %v", got)
+	}
+
+	p.Backup(5)
+
+	if p.Len() != 0 {
+		t.Errorf("test 4: expecting Len 0, got %d", p.Len())
+	} else if tk := p.Next(); tk != (Token{Type: treeWord, Data: "b"}) {
+		t.Errorf("test 5: expecting word token \"b\", got %v", tk)
+	}
+
+	p.Next()
+	p.Next()
+	p.Next()
+	p.Next()
+	p.Backup(2)
+
+	if p.Len() != 2 {
+		t.Errorf("test 6: expecting Len 2, got %d", p.Len())
+	} else if tk := p.Next(); tk != (Token{Type: treeWord, Data: "c"}) {
+		t.Errorf("test 7: expecting word token \"c\", got %v", tk)
+	} else if tk := p.Next(); tk.Type != TokenDone {
+		t.Errorf("test 8: expecting TokenDone, got %v", tk)
+	} else if p.Len() != 4 {
+		t.Errorf("test 9: expecting Len 4, got %d", p.Len())
+	}
+}
+
+func TestParserState(t *testing.T) {
+	p := New(NewStringTokeniser("a(b)c"))
+
+	p.TokeniserState(treeTokeniser)
+
+	p.Next()
+
+	s := p.State()
+
+	p.Next()
+	p.Next()
+	p.Next()
+
+	if !s.Reset() {
+		t.Errorf("test 1: expecting successful reset")
+	} else if p.Len() != 1 {
+		t.Errorf("test 2: expecting Len 1, got %d", p.Len())
+	} else if tk := p.Next(); tk != (Token{Type: treeOpen, Data: "("}) {
+		t.Errorf("test 3: expecting open token, got %v", tk)
+	} else if got := p.Get(); !reflect.DeepEqual(got, []Token{{Type: treeWord, Data: "a"}, {Type: treeOpen, Data: "("}}) {
+		t.Errorf("test 4: unexpected tokens: %v", got)
+	} else if s.Reset() {
+		t.Errorf("test 5: expecting failed reset after Get")
+	}
+
+	s = p.State()
+
+	p.Next()
+	p.Next()
+	p.Next()
+	p.Next()
+	p.Reset()
+
+	if p.Len() != 0 {
+		t.Errorf("test 6: expecting Len 0, got %d", p.Len())
+	} else if tk := p.Next(); tk != (Token{Type: treeWord, Data: "b"}) {
+		t.Errorf("test 7: expecting word token \"b\", got %v", tk)
+	} else if p.Get(); s.Reset() {
+		t.Errorf("test 8: expecting failed reset after Get")
+	}
+
+	s = p.State()
+
+	p.Get()
+	p.Next()
+
+	if !s.Reset() {
+		t.Errorf("test 9: expecting successful reset")
+	} else if tk := p.Next(); tk != (Token{Type: treeClose, Data: ")"}) {
+		t.Errorf("test 10: expecting close token, got %v", tk)
+	}
+}
+
+func TestParserEmitSpans(t *testing.T) {
+	p := New(NewStringTokeniser("a  >> bc"))
+
+	p.TokeniserState(emitTokeniser)
+
+	expected := []Span{
+		{Start: 0, End: 1},
+		{Start: 1, End: 1},
+		{Start: 3, End: 4},
+		{Start: 4, End: 5},
+		{Start: 6, End: 8},
+		{Start: 8, End: 8},
+		{Start: 8, End: 8},
+	}
+
+	for n, span := range expected {
+		if offset := p.Offset(); offset != span.Start {
+			t.Errorf("test %d: expecting start %d, got %d", n+1, span.Start, offset)
+		}
+
+		p.Next()
+
+		if end := p.End(); end != span.End {
+			t.Errorf("test %d: expecting end %d, got %d", n+1, span.End, end)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package parser
+
+import "context"
+
+type batch struct {
+	tokens []queued
+	err    error
+}
+
+type pipeline struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	ch     <-chan batch
+	batch  batch
+	last   *queued
+}
+
+// Pipeline starts running the TokenFuncs of the Tokeniser in a new goroutine,
+// which sends the generated Tokens, in batches of up to size Tokens, through
+// a channel that buffers up to buffer batches. The Tokens are then returned,
+// in order, by GetToken, Iter, and any Parser using the Tokeniser, allowing
+// tokenising and parsing to run concurrently.
+//
+// Once the pipeline has started, the reading methods of the Tokeniser, such as
+// Accept and Get, must not be used, as the input belongs to the goroutine.
+//
+// The goroutine stops after generating a TokenDone or TokenError Token, or
+// when the given context is cancelled, or the pipeline is stopped with
+// StopPipeline. Stopping Iter early, on either the Tokeniser or a Parser using
+// it, also stops the pipeline.
+//
+// If the pipeline is stopped before the final Token is received, a TokenError
+// Token is returned, with Err set to the cause of the cancellation.
+//
+// A size or buffer of less than one is treated as one.
+func (t *Tokeniser) Pipeline(ctx context.Context, size, buffer int) {
+	ctx, cancel := context.WithCancel(ctx)
+	ch := make(chan batch, max(buffer, 1))
+	pl := &pipeline{
+		ctx:    ctx,
+		cancel: cancel,
+		ch:     ch,
+	}
+	producer := &Tokeniser{
+		tokeniser: t.tokeniser,
+		Err:       t.Err,
+		state:     t.state,
+		offset:    t.offset,
+		end:       t.end,
+		queue:     t.queue,
+		heredocs:  t.heredocs,
+	}
+
+	*t = Tokeniser{
+		tokeniser: &strParser{},
+		Err:       t.Err,
+		offset:    t.offset,
+		end:       t.end,
+		pipe:      pl,
+	}
+
+	go producer.produce(ctx, ch, max(size, 1))
+}
+
+func (t *Tokeniser) produce(ctx context.Context, ch chan<- batch, size int) {
+	tokens := make([]queued, 0, size)
+
+	for ctx.Err() == nil {
+		tk := t.get()
+		final := tk.Type == TokenDone || tk.Type == TokenError
+		tokens = append(tokens, queued{token: tk, end: t.end})
+
+		if len(tokens) < size && !final {
+			continue
+		}
+
+		b := batch{tokens: tokens}
+
+		if final {
+			b.err = t.Err
+		}
+
+		select {
+		case ch <- b:
+		case <-ctx.Done():
+			return
+		}
+
+		if final {
+			return
+		}
+
+		tokens = make([]queued, 0, size)
+	}
+}
+
+// StopPipeline stops the goroutine started by Pipeline, if any.
+//
+// It does not wait for the goroutine to exit, as it may be blocked reading
+// the input, but no more TokenFuncs will be called after the current one
+// returns.
+func (t *Tokeniser) StopPipeline() {
+	if t.pipe != nil {
+		t.pipe.cancel()
+	}
+}
+
+func (p *pipeline) get(t *Tokeniser) Token {
+	for len(p.batch.tokens) == 0 {
+		if p.last != nil {
+			t.end = p.last.end
+			t.offset = p.last.end
+
+			return p.last.token
+		}
+
+		select {
+		case p.batch = <-p.ch:
+		case <-p.ctx.Done():
+			t.Err = context.Cause(p.ctx)
+			p.last = &queued{
+				token: Token{
+					Type: TokenError,
+					Data: t.Err.Error(),
+				},
+				end: t.end,
+			}
+		}
+	}
+
+	q := p.batch.tokens[0]
+	p.batch.tokens = p.batch.tokens[1:]
+
+	if q.token.Type == TokenDone || q.token.Type == TokenError {
+		t.Err = p.batch.err
+		p.last = &q
+
+		p.cancel()
+	}
+
+	t.end = q.end
+	t.offset = q.end
+
+	return q.token
+}
@@ -0,0 +1,169 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestPipeline(t *testing.T) {
+	expected := []Token{
+		{Type: 0, Data: "a"},
+		{Type: 2},
+		{Type: 1, Data: ">"},
+		{Type: 1, Data: ">"},
+		{Type: 1, Data: ">"},
+		{Type: 0, Data: "bc"},
+		{Type: 2},
+		{Type: TokenDone},
+	}
+
+	for _, size := range [...]int{0, 1, 3, 100} {
+		for n, p := range tokenisers("a  >>> bc") {
+			p.TokeniserState(emitTokeniser)
+			p.Pipeline(context.Background(), size, 2)
+
+			var tokens []Token
+
+			for tk := range p.Iter {
+				tokens = append(tokens, tk)
+			}
+
+			if !reflect.DeepEqual(tokens, expected) {
+				t.Errorf("test %d (%s): expecting tokens %v, got %v", size, n, expected, tokens)
+			} else if tk, err := p.GetToken(); tk.Type != TokenDone || err != nil {
+				t.Errorf("test %d (%s): expecting TokenDone and nil error, got %v and %v", size, n, tk, err)
+			} else if !errors.Is(p.Err, io.EOF) {
+				t.Errorf("test %d (%s): expecting error %v, got %v", size, n, io.EOF, p.Err)
+			}
+		}
+	}
+}
+
+func TestPipelineSpans(t *testing.T) {
+	p := New(NewStringTokeniser("a  >> bc"))
+
+	p.TokeniserState(emitTokeniser)
+	p.Pipeline(context.Background(), 2, 1)
+
+	expected := []Span{
+		{Start: 0, End: 1},
+		{Start: 1, End: 1},
+		{Start: 3, End: 4},
+		{Start: 4, End: 5},
+		{Start: 6, End: 8},
+		{Start: 8, End: 8},
+		{Start: 8, End: 8},
+	}
+
+	for n, span := range expected {
+		if offset := p.Offset(); offset != span.Start {
+			t.Errorf("test %d: expecting start %d, got %d", n+1, span.Start, offset)
+		}
+
+		p.Next()
+
+		if end := p.End(); end != span.End {
+			t.Errorf("test %d: expecting end %d, got %d", n+1, span.End, end)
+		}
+	}
+}
+
+func TestPipelineError(t *testing.T) {
+	errTest := errors.New("test error")
+
+	p := NewStringTokeniser("abc")
+
+	p.TokeniserState(func(t *Tokeniser) (Token, TokenFunc) {
+		t.Next()
+
+		return t.Return(1, func(t *Tokeniser) (Token, TokenFunc) {
+			return t.ReturnError(errTest)
+		})
+	})
+	p.Pipeline(context.Background(), 10, 1)
+
+	if tk, err := p.GetToken(); tk != (Token{Type: 1, Data: "a"}) || err != nil {
+		t.Errorf("test 1: expecting token %v and nil error, got %v and %v", Token{Type: 1, Data: "a"}, tk, err)
+	}
+
+	for n := range 2 {
+		if tk, err := p.GetToken(); tk.Type != TokenError || !errors.Is(err, errTest) {
+			t.Errorf("test %d: expecting TokenError and error %v, got %v and %v", n+2, errTest, tk, err)
+		}
+	}
+}
+
+func infiniteTokeniser(t *Tokeniser) (Token, TokenFunc) {
+	return Token{Type: 1}, infiniteTokeniser
+}
+
+func infiniteParser(p *Parser) (Phrase, PhraseFunc) {
+	p.Next()
+
+	return p.Return(1, infiniteParser)
+}
+
+func checkStopped(t *testing.T, p *Tokeniser) {
+	t.Helper()
+
+	select {
+	case <-p.pipe.ctx.Done():
+	default:
+		t.Errorf("pipeline was not stopped")
+	}
+}
+
+func TestPipelineIterStop(t *testing.T) {
+	p := NewStringTokeniser("")
+
+	p.TokeniserState(infiniteTokeniser)
+	p.Pipeline(context.Background(), 4, 2)
+
+	var count int
+
+	for range p.Iter {
+		if count++; count == 10 {
+			break
+		}
+	}
+
+	checkStopped(t, &p)
+}
+
+func TestPipelineParserIterStop(t *testing.T) {
+	p := New(NewStringTokeniser(""))
+
+	p.TokeniserState(infiniteTokeniser)
+	p.PhraserState(infiniteParser)
+	p.Pipeline(context.Background(), 4, 2)
+
+	for range p.Iter {
+		break
+	}
+
+	checkStopped(t, &p.Tokeniser)
+}
+
+func TestPipelineCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := NewStringTokeniser("")
+
+	p.TokeniserState(infiniteTokeniser)
+	p.Pipeline(ctx, 1, 1)
+	p.GetToken()
+	cancel()
+	checkStopped(t, &p)
+
+	tk, err := p.GetToken()
+
+	for n := 0; n < 3 && tk.Type != TokenError; n++ {
+		tk, err = p.GetToken()
+	}
+
+	if tk.Type != TokenError || !errors.Is(err, context.Canceled) {
+		t.Errorf("expecting TokenError and error %v, got %v and %v", context.Canceled, tk, err)
+	}
+}
@@ -0,0 +1,286 @@
+// Package pratt implements a Pratt (operator-precedence) expression parser for
+// use with a parser.Parser.
+package pratt // import "vimagination.zapto.org/parser/pratt"
+
+import (
+	"io"
+
+	"vimagination.zapto.org/parser"
+)
+
+// Associativity determines how operators of equal binding power are grouped.
+type Associativity uint8
+
+// Associativity values.
+const (
+	Left Associativity = iota
+	Right
+)
+
+// PrefixFunc is called with a Token that begins an expression, such as a
+// literal, a unary operator or an opening bracket, and returns the Node for
+// that expression.
+type PrefixFunc func(s *State, tk parser.Token) (*parser.Node, error)
+
+// InfixFunc is called with an infix or postfix operator Token, and the Node for
+// the expression to its left, and returns the Node for the combined
+// expression.
+type InfixFunc func(s *State, left *parser.Node, tk parser.Token) (*parser.Node, error)
+
+type prefix struct {
+	bp int
+	fn PrefixFunc
+}
+
+type infix struct {
+	bp    int
+	assoc Associativity
+	fn    InfixFunc
+}
+
+// Grammar contains the handlers used to parse expressions, keyed by
+// TokenType.
+//
+// Binding powers must be greater than zero, with higher binding powers binding
+// more tightly.
+type Grammar struct {
+	// Names, which may be nil, is used to name TokenTypes in errors.
+	Names *parser.Names
+
+	prefix  map[parser.TokenType]prefix
+	infix   map[parser.TokenType]infix
+	postfix map[parser.TokenType]infix
+}
+
+// Prefix registers a handler for a Token that can begin an expression.
+//
+// The binding power is used when the handler calls State.Operand to parse its
+// operand.
+func (g *Grammar) Prefix(typ parser.TokenType, bp int, fn PrefixFunc) {
+	if g.prefix == nil {
+		g.prefix = make(map[parser.TokenType]prefix)
+	}
+
+	g.prefix[typ] = prefix{bp: bp, fn: fn}
+}
+
+// Infix registers a handler for a binary operator with the given binding power
+// and associativity.
+func (g *Grammar) Infix(typ parser.TokenType, bp int, assoc Associativity, fn InfixFunc) {
+	if g.infix == nil {
+		g.infix = make(map[parser.TokenType]infix)
+	}
+
+	g.infix[typ] = infix{bp: bp, assoc: assoc, fn: fn}
+}
+
+// Postfix registers a handler for a postfix operator with the given binding
+// power.
+func (g *Grammar) Postfix(typ parser.TokenType, bp int, fn InfixFunc) {
+	if g.postfix == nil {
+		g.postfix = make(map[parser.TokenType]infix)
+	}
+
+	g.postfix[typ] = infix{bp: bp, fn: fn}
+}
+
+// Parse reads a single expression from the Parser, returning the root Node of
+// the expression tree.
+//
+// The Tokens consumed remain in the Parser and can be retrieved with
+// Parser.Get or Parser.Return as usual.
+func (g *Grammar) Parse(p *parser.Parser) (*parser.Node, error) {
+	return g.parse(p, 0)
+}
+
+func (g *Grammar) parse(p *parser.Parser, minBP int) (*parser.Node, error) {
+	tk := p.Peek()
+
+	pre, ok := g.prefix[tk.Type]
+	if !ok {
+		return nil, g.unexpected(p, tk)
+	}
+
+	span := next(p)
+
+	left, err := pre.fn(&State{Parser: p, grammar: g, bp: pre.bp, span: span}, tk)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		tk = p.Peek()
+
+		if post, ok := g.postfix[tk.Type]; ok {
+			if post.bp <= minBP {
+				break
+			}
+
+			span := next(p)
+
+			if left, err = post.fn(&State{Parser: p, grammar: g, bp: post.bp, span: span}, left, tk); err != nil {
+				return nil, err
+			}
+		} else if in, ok := g.infix[tk.Type]; ok {
+			if in.bp <= minBP {
+				break
+			}
+
+			span := next(p)
+			bp := in.bp
+
+			if in.assoc == Right {
+				bp--
+			}
+
+			if left, err = in.fn(&State{Parser: p, grammar: g, bp: bp, span: span}, left, tk); err != nil {
+				return nil, err
+			}
+		} else {
+			break
+		}
+	}
+
+	return left, nil
+}
+
+func next(p *parser.Parser) parser.Span {
+	start := p.Offset()
+
+	p.Next()
+
+	return parser.Span{Start: start, End: p.End()}
+}
+
+func node(typ parser.PhraseType, children ...parser.Child) *parser.Node {
+	return &parser.Node{
+		Type:     typ,
+		Children: children,
+		Span:     parser.Span{Start: children[0].Span.Start, End: children[len(children)-1].Span.End},
+	}
+}
+
+func (g *Grammar) unexpected(p *parser.Parser, tk parser.Token) error {
+	switch tk.Type {
+	case parser.TokenDone:
+		return io.ErrUnexpectedEOF
+	case parser.TokenError:
+		return p.Err
+	}
+
+	return &parser.UnexpectedTokenError{
+		Token:  tk,
+		Name:   g.Names.Token(tk.Type),
+		Offset: p.Offset(),
+	}
+}
+
+// State is passed to the handlers, allowing them to parse sub-expressions.
+type State struct {
+	*parser.Parser
+	grammar *Grammar
+	bp      int
+	span    parser.Span
+}
+
+// Span returns the Span of the Token that the handler was called for.
+func (s *State) Span() parser.Span {
+	return s.span
+}
+
+// Operand parses the operand of the current operator, using its binding power,
+// taking into account the associativity of infix operators.
+func (s *State) Operand() (*parser.Node, error) {
+	return s.grammar.parse(s.Parser, s.bp)
+}
+
+// Expression parses a complete sub-expression, such as one within brackets.
+func (s *State) Expression() (*parser.Node, error) {
+	return s.grammar.parse(s.Parser, 0)
+}
+
+// Unexpected returns an error for the given Token, which should be the next
+// Token.
+//
+// The returned error will be a *parser.UnexpectedTokenError, giving the offset
+// of the next Token, unless the Token is a TokenDone or TokenError, in which
+// case io.ErrUnexpectedEOF or the error of the Tokeniser is returned.
+func (s *State) Unexpected(tk parser.Token) error {
+	return s.grammar.unexpected(s.Parser, tk)
+}
+
+// Leaf returns a PrefixFunc that creates a Node of the given type containing
+// just the Token, for use with literals and identifiers.
+func Leaf(typ parser.PhraseType) PrefixFunc {
+	return func(s *State, tk parser.Token) (*parser.Node, error) {
+		return node(typ, parser.Child{Token: tk, Span: s.Span()}), nil
+	}
+}
+
+// Unary returns a PrefixFunc for a prefix operator, creating a Node of the
+// given type containing the operator Token and the operand Node.
+func Unary(typ parser.PhraseType) PrefixFunc {
+	return func(s *State, tk parser.Token) (*parser.Node, error) {
+		operand, err := s.Operand()
+		if err != nil {
+			return nil, err
+		}
+
+		return node(typ,
+			parser.Child{Token: tk, Span: s.Span()},
+			parser.Child{Node: operand, Span: operand.Span},
+		), nil
+	}
+}
+
+// Group returns a PrefixFunc for an opening bracket, parsing an expression
+// followed by a closing bracket of the given TokenType, and creating a Node of
+// the given type containing the opening Token, the expression Node and the
+// closing Token.
+func Group(typ parser.PhraseType, closing parser.TokenType) PrefixFunc {
+	return func(s *State, tk parser.Token) (*parser.Node, error) {
+		expr, err := s.Expression()
+		if err != nil {
+			return nil, err
+		}
+
+		end := s.Peek()
+		if end.Type != closing {
+			return nil, s.Unexpected(end)
+		}
+
+		return node(typ,
+			parser.Child{Token: tk, Span: s.Span()},
+			parser.Child{Node: expr, Span: expr.Span},
+			parser.Child{Token: end, Span: next(s.Parser)},
+		), nil
+	}
+}
+
+// Binary returns an InfixFunc for a binary operator, creating a Node of the
+// given type containing the left Node, the operator Token and the right Node.
+func Binary(typ parser.PhraseType) InfixFunc {
+	return func(s *State, left *parser.Node, tk parser.Token) (*parser.Node, error) {
+		right, err := s.Operand()
+		if err != nil {
+			return nil, err
+		}
+
+		return node(typ,
+			parser.Child{Node: left, Span: left.Span},
+			parser.Child{Token: tk, Span: s.Span()},
+			parser.Child{Node: right, Span: right.Span},
+		), nil
+	}
+}
+
+// Suffix returns an InfixFunc for a postfix operator, creating a Node of the
+// given type containing the operand Node and the operator Token.
+func Suffix(typ parser.PhraseType) InfixFunc {
+	return func(s *State, left *parser.Node, tk parser.Token) (*parser.Node, error) {
+		return node(typ,
+			parser.Child{Node: left, Span: left.Span},
+			parser.Child{Token: tk, Span: s.Span()},
+		), nil
+	}
+}
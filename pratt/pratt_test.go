@@ -0,0 +1,227 @@
+package pratt
+
+import (
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+
+	"vimagination.zapto.org/parser"
+)
+
+const (
+	tokenNumber parser.TokenType = iota
+	tokenOpen
+	tokenClose
+	tokenAdd
+	tokenSub
+	tokenMul
+	tokenDiv
+	tokenPow
+	tokenFact
+)
+
+const (
+	phraseNumber parser.PhraseType = iota
+	phraseUnary
+	phraseBinary
+	phrasePostfix
+	phraseGroup
+)
+
+func tokeniser(t *parser.Tokeniser) (parser.Token, parser.TokenFunc) {
+	if t.AcceptRun(" "); t.Len() > 0 {
+		t.Get()
+	}
+
+	switch {
+	case t.Peek() == -1:
+		return t.Done()
+	case t.Accept("("):
+		return t.Return(tokenOpen, tokeniser)
+	case t.Accept(")"):
+		return t.Return(tokenClose, tokeniser)
+	case t.Accept("0123456789"):
+		t.AcceptRun("0123456789")
+
+		return t.Return(tokenNumber, tokeniser)
+	case t.Accept("+"):
+		return t.Return(tokenAdd, tokeniser)
+	case t.Accept("-"):
+		return t.Return(tokenSub, tokeniser)
+	case t.Accept("*"):
+		return t.Return(tokenMul, tokeniser)
+	case t.Accept("/"):
+		return t.Return(tokenDiv, tokeniser)
+	case t.Accept("^"):
+		return t.Return(tokenPow, tokeniser)
+	case t.Accept("!"):
+		return t.Return(tokenFact, tokeniser)
+	}
+
+	return t.ReturnError(errors.New("invalid character"))
+}
+
+func arithmetic() *Grammar {
+	var g Grammar
+
+	g.Names = &parser.Names{
+		Tokens: map[parser.TokenType]string{
+			tokenNumber: "Number",
+			tokenClose:  "Close",
+			tokenAdd:    "Add",
+			tokenMul:    "Multiply",
+		},
+	}
+
+	g.Prefix(tokenNumber, 0, Leaf(phraseNumber))
+	g.Prefix(tokenOpen, 0, Group(phraseGroup, tokenClose))
+	g.Prefix(tokenSub, 30, Unary(phraseUnary))
+	g.Infix(tokenAdd, 10, Left, Binary(phraseBinary))
+	g.Infix(tokenSub, 10, Left, Binary(phraseBinary))
+	g.Infix(tokenMul, 20, Left, Binary(phraseBinary))
+	g.Infix(tokenDiv, 20, Left, Binary(phraseBinary))
+	g.Infix(tokenPow, 40, Right, Binary(phraseBinary))
+	g.Postfix(tokenFact, 50, Suffix(phrasePostfix))
+
+	return &g
+}
+
+func format(n *parser.Node) string {
+	var sb strings.Builder
+
+	if len(n.Children) == 1 {
+		return n.Children[0].Token.Data
+	}
+
+	sb.WriteString("(")
+
+	for m, c := range n.Children {
+		if m > 0 {
+			sb.WriteString(" ")
+		}
+
+		if c.Node != nil {
+			sb.WriteString(format(c.Node))
+		} else {
+			sb.WriteString(c.Token.Data)
+		}
+	}
+
+	sb.WriteString(")")
+
+	return sb.String()
+}
+
+func TestPratt(t *testing.T) {
+	for n, test := range [...]struct {
+		Input, Output string
+		Err           error
+		ErrString     string
+	}{
+		{
+			Input:  "1",
+			Output: "1",
+		},
+		{
+			Input:  "1 + 2",
+			Output: "(1 + 2)",
+		},
+		{
+			Input:  "1 + 2 * 3",
+			Output: "(1 + (2 * 3))",
+		},
+		{
+			Input:  "1 * 2 + 3",
+			Output: "((1 * 2) + 3)",
+		},
+		{
+			Input:  "1 - 2 - 3",
+			Output: "((1 - 2) - 3)",
+		},
+		{
+			Input:  "2 ^ 3 ^ 4",
+			Output: "(2 ^ (3 ^ 4))",
+		},
+		{
+			Input:  "-2 ^ 2",
+			Output: "(- (2 ^ 2))",
+		},
+		{
+			Input:  "-3! * 2",
+			Output: "((- (3 !)) * 2)",
+		},
+		{
+			Input:  "(1 + 2) * 3",
+			Output: "((( (1 + 2) )) * 3)",
+		},
+		{
+			Input:     "1 + * 2",
+			Err:       parser.ErrUnexpectedToken,
+			ErrString: "unexpected Multiply \"*\" at offset 4",
+		},
+		{
+			Input: "1 +",
+			Err:   io.ErrUnexpectedEOF,
+		},
+		{
+			Input:     "(1 + 2 3",
+			Err:       parser.ErrUnexpectedToken,
+			ErrString: "unexpected Number \"3\" at offset 7",
+		},
+	} {
+		p := parser.New(parser.NewStringTokeniser(test.Input))
+
+		p.TokeniserState(tokeniser)
+
+		node, err := arithmetic().Parse(&p)
+		if !errors.Is(err, test.Err) {
+			t.Errorf("test %d: expecting error %v, got %v", n+1, test.Err, err)
+		} else if test.ErrString != "" && err.Error() != test.ErrString {
+			t.Errorf("test %d: expecting error string %q, got %q", n+1, test.ErrString, err.Error())
+		} else if err == nil {
+			if out := format(node); out != test.Output {
+				t.Errorf("test %d: expecting output %q, got %q", n+1, test.Output, out)
+			}
+		}
+	}
+}
+
+type spanCollector struct {
+	input string
+	spans []string
+}
+
+func (s *spanCollector) Enter(n *parser.Node) parser.Visitor {
+	s.spans = append(s.spans, s.input[n.Span.Start:n.Span.End])
+
+	return s
+}
+
+func (s *spanCollector) Token(parser.Token) {}
+
+func (s *spanCollector) Leave(*parser.Node) {}
+
+func TestPrattSpans(t *testing.T) {
+	const input = "-(1 + 22)! * 3"
+
+	p := parser.New(parser.NewStringTokeniser(input))
+
+	p.TokeniserState(tokeniser)
+
+	node, err := arithmetic().Parse(&p)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	sc := spanCollector{input: input}
+
+	parser.Walk(&sc, node)
+
+	expected := []string{"-(1 + 22)! * 3", "-(1 + 22)!", "(1 + 22)!", "(1 + 22)", "1 + 22", "1", "22", "3"}
+
+	if !slices.Equal(sc.spans, expected) {
+		t.Errorf("expecting spans %q, got %q", expected, sc.spans)
+	}
+}
@@ -0,0 +1,211 @@
+package pretty
+
+import (
+	"vimagination.zapto.org/parser"
+)
+
+// NodeFunc builds the Doc for a Node, given the Docs of each of its children.
+type NodeFunc func(n *parser.Node, children []Doc) Doc
+
+// Formatter builds Docs from the Tokens and Phrases of a parser.Parser.
+//
+// Trivia attached to the Tokens of a tree, in lossless mode, is not included
+// in the built Docs, but can be used by NodeFuncs.
+type Formatter struct {
+	// Token, if set, converts a Token to a Doc; by default, the Data of the
+	// Token is converted with Text.
+	Token func(parser.Token) Doc
+
+	// Space, if set, returns the Doc to be placed between two adjacent
+	// Tokens, such as Line() or Text(" "); by default, nothing is placed
+	// between Tokens.
+	Space func(prev, next parser.Token) Doc
+
+	// Nodes contains the NodeFuncs used to build the Docs for Nodes of each
+	// PhraseType. Nodes of other types are built by concatenating the Docs of
+	// their children, with the Doc returned by Space between them.
+	Nodes map[parser.PhraseType]NodeFunc
+
+	// Separator, if set, is placed between Phrases; by default, HardLine is
+	// used.
+	Separator Doc
+}
+
+func (f *Formatter) token(tk parser.Token) Doc {
+	if f.Token != nil {
+		return f.Token(tk)
+	}
+
+	return Text(tk.Data)
+}
+
+func (f *Formatter) space(prev, next parser.Token) Doc {
+	if f.Space != nil {
+		return f.Space(prev, next)
+	}
+
+	return nil
+}
+
+// Tokens builds a Doc from the given Tokens, with the Doc returned by Space
+// between each adjacent pair.
+//
+// TokenDone and TokenError Tokens are ignored.
+func (f *Formatter) Tokens(tokens []parser.Token) Doc {
+	var (
+		docs concat
+		prev parser.Token
+	)
+
+	for _, tk := range tokens {
+		if tk.Type < 0 {
+			continue
+		}
+
+		if len(docs) > 0 {
+			if sp := f.space(prev, tk); sp != nil {
+				docs = append(docs, sp)
+			}
+		}
+
+		docs = append(docs, f.token(tk))
+		prev = tk
+	}
+
+	return docs
+}
+
+// Node builds a Doc from the tree rooted at the given Node.
+func (f *Formatter) Node(n *parser.Node) Doc {
+	children := make([]Doc, len(n.Children))
+
+	for i, c := range n.Children {
+		if c.Node != nil {
+			children[i] = f.Node(c.Node)
+		} else if c.Token.Type >= 0 {
+			children[i] = f.token(c.Token)
+		} else {
+			children[i] = concat{}
+		}
+	}
+
+	if fn, ok := f.Nodes[n.Type]; ok {
+		return fn(n, children)
+	}
+
+	var (
+		docs concat
+		prev parser.Token
+		has  bool
+	)
+
+	for i, c := range n.Children {
+		first, last, ok := bounds(c)
+		if !ok {
+			continue
+		}
+
+		if has {
+			if sp := f.space(prev, first); sp != nil {
+				docs = append(docs, sp)
+			}
+		}
+
+		docs = append(docs, children[i])
+		prev, has = last, true
+	}
+
+	return docs
+}
+
+func bounds(c parser.Child) (first, last parser.Token, ok bool) {
+	if c.Node == nil {
+		return c.Token, c.Token, c.Token.Type >= 0
+	}
+
+	if first, ok = firstToken(c.Node); ok {
+		last, _ = lastToken(c.Node)
+	}
+
+	return first, last, ok
+}
+
+// firstToken walks down the tree to the first Token that is not a TokenDone or
+// TokenError.
+func firstToken(n *parser.Node) (parser.Token, bool) {
+	for _, c := range n.Children {
+		if c.Node == nil {
+			if c.Token.Type >= 0 {
+				return c.Token, true
+			}
+		} else if tk, ok := firstToken(c.Node); ok {
+			return tk, true
+		}
+	}
+
+	return parser.Token{}, false
+}
+
+// lastToken walks down the tree to the last Token that is not a TokenDone or
+// TokenError.
+func lastToken(n *parser.Node) (parser.Token, bool) {
+	for i := len(n.Children) - 1; i >= 0; i-- {
+		if c := n.Children[i]; c.Node == nil {
+			if c.Token.Type >= 0 {
+				return c.Token, true
+			}
+		} else if tk, ok := lastToken(c.Node); ok {
+			return tk, true
+		}
+	}
+
+	return parser.Token{}, false
+}
+
+// Phrase builds a Doc from the given Phrase, using its Tree if it has one, and
+// its Data otherwise.
+func (f *Formatter) Phrase(ph parser.Phrase) Doc {
+	if ph.Tree != nil {
+		return f.Node(ph.Tree)
+	}
+
+	return f.Tokens(ph.Data)
+}
+
+// Parse reads all of the Phrases from the given Parser, building a Doc from
+// each, and returning them joined by the Separator.
+//
+// Phrases that contain no Tokens are skipped.
+func (f *Formatter) Parse(p *parser.Parser) (Doc, error) {
+	sep := f.Separator
+	if sep == nil {
+		sep = HardLine()
+	}
+
+	var docs []Doc
+
+	for {
+		ph, err := p.GetPhrase()
+		if err != nil {
+			return nil, err
+		}
+
+		if ph.Type == parser.PhraseDone {
+			return Join(sep, docs...), nil
+		}
+
+		if hasTokens(ph) {
+			docs = append(docs, f.Phrase(ph))
+		}
+	}
+}
+
+func hasTokens(ph parser.Phrase) bool {
+	for _, tk := range ph.Data {
+		if tk.Type >= 0 {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,263 @@
+// Package pretty implements a Wadler-style pretty printer, which lays out
+// documents built from text, line breaks, groups and nesting to fit within a
+// given width.
+package pretty // import "vimagination.zapto.org/parser/pretty"
+
+import (
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// Doc is a document that can be laid out by Render.
+//
+// Docs are built with the functions of this package; Text for text, Line,
+// SoftLine and HardLine for possible line breaks, and Concat, Nest and Group
+// to combine them.
+type Doc interface {
+	isDoc()
+}
+
+type text string
+
+type line struct {
+	flat string
+	hard bool
+}
+
+type concat []Doc
+
+type nest struct {
+	indent int
+	doc    Doc
+}
+
+type group struct {
+	doc  Doc
+	hard bool
+}
+
+func (text) isDoc()   {}
+func (line) isDoc()   {}
+func (concat) isDoc() {}
+func (nest) isDoc()   {}
+func (group) isDoc()  {}
+
+// Text returns a Doc containing the given text, which should not contain any
+// newlines.
+func Text(s string) Doc {
+	return text(s)
+}
+
+// Line returns a Doc that is a line break, unless the enclosing Group fits on
+// one line, in which case it is a single space.
+func Line() Doc {
+	return line{flat: " "}
+}
+
+// SoftLine returns a Doc that is a line break, unless the enclosing Group fits
+// on one line, in which case it is empty.
+func SoftLine() Doc {
+	return line{}
+}
+
+// HardLine returns a Doc that is always a line break, and which causes all
+// enclosing Groups to be broken.
+func HardLine() Doc {
+	return line{hard: true}
+}
+
+// Concat returns a Doc containing each of the given Docs in order.
+func Concat(docs ...Doc) Doc {
+	return concat(docs)
+}
+
+// Join returns a Doc containing each of the given Docs, with sep between each
+// pair.
+func Join(sep Doc, docs ...Doc) Doc {
+	joined := make(concat, 0, max(len(docs)*2-1, 0))
+
+	for n, d := range docs {
+		if n > 0 {
+			joined = append(joined, sep)
+		}
+
+		joined = append(joined, d)
+	}
+
+	return joined
+}
+
+// Nest returns a Doc containing the given Docs, in which each line break is
+// followed by an additional indent of the given number of spaces.
+func Nest(indent int, docs ...Doc) Doc {
+	return nest{indent: indent, doc: concat(docs)}
+}
+
+// Group returns a Doc containing the given Docs, which will be laid out on a
+// single line, with each Line and SoftLine flattened, if it fits within the
+// width; otherwise, each Line and SoftLine directly within the Group will be a
+// line break.
+func Group(docs ...Doc) Doc {
+	d := concat(docs)
+
+	return group{doc: d, hard: hasHardLine(d)}
+}
+
+func hasHardLine(d Doc) bool {
+	switch d := d.(type) {
+	case line:
+		return d.hard
+	case concat:
+		for _, c := range d {
+			if hasHardLine(c) {
+				return true
+			}
+		}
+	case nest:
+		return hasHardLine(d.doc)
+	case group:
+		return d.hard
+	}
+
+	return false
+}
+
+type mode uint8
+
+const (
+	flat mode = iota
+	broken
+)
+
+type command struct {
+	indent int
+	mode   mode
+	doc    Doc
+}
+
+type printer struct {
+	w       io.Writer
+	col     int
+	pending int
+	err     error
+}
+
+func (p *printer) write(s string) {
+	if p.err != nil || s == "" {
+		return
+	}
+
+	if p.pending > 0 {
+		_, p.err = io.WriteString(p.w, strings.Repeat(" ", p.pending))
+		p.pending = 0
+	}
+
+	if p.err == nil {
+		_, p.err = io.WriteString(p.w, s)
+		p.col += utf8.RuneCountInString(s)
+	}
+}
+
+func (p *printer) newline(indent int) {
+	if p.err == nil {
+		_, p.err = io.WriteString(p.w, "\n")
+		p.col = indent
+		p.pending = indent
+	}
+}
+
+// Render lays out the given Doc, writing it to the given Writer, breaking
+// Groups as necessary to keep lines within the given width.
+//
+// Lines may exceed the width when there is no way to break them.
+func Render(w io.Writer, width int, d Doc) error {
+	p := printer{w: w}
+	stack := []command{{mode: broken, doc: d}}
+
+	for len(stack) > 0 && p.err == nil {
+		c := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		switch d := c.doc.(type) {
+		case text:
+			p.write(string(d))
+		case line:
+			if c.mode == flat && !d.hard {
+				p.write(d.flat)
+			} else {
+				p.newline(c.indent)
+			}
+		case concat:
+			for i := len(d) - 1; i >= 0; i-- {
+				stack = append(stack, command{indent: c.indent, mode: c.mode, doc: d[i]})
+			}
+		case nest:
+			stack = append(stack, command{indent: c.indent + d.indent, mode: c.mode, doc: d.doc})
+		case group:
+			m := broken
+
+			if c.mode == flat || !d.hard && fits(width-p.col, command{indent: c.indent, mode: flat, doc: d.doc}, stack) {
+				m = flat
+			}
+
+			stack = append(stack, command{indent: c.indent, mode: m, doc: d.doc})
+		}
+	}
+
+	return p.err
+}
+
+func fits(width int, c command, rest []command) bool {
+	cmds := []command{c}
+
+	for width >= 0 {
+		if len(cmds) == 0 {
+			if len(rest) == 0 {
+				return true
+			}
+
+			cmds = append(cmds, rest[len(rest)-1])
+			rest = rest[:len(rest)-1]
+		}
+
+		c := cmds[len(cmds)-1]
+		cmds = cmds[:len(cmds)-1]
+
+		switch d := c.doc.(type) {
+		case text:
+			width -= utf8.RuneCountInString(string(d))
+		case line:
+			if c.mode == broken || d.hard {
+				return true
+			}
+
+			width -= utf8.RuneCountInString(d.flat)
+		case concat:
+			for i := len(d) - 1; i >= 0; i-- {
+				cmds = append(cmds, command{indent: c.indent, mode: c.mode, doc: d[i]})
+			}
+		case nest:
+			cmds = append(cmds, command{indent: c.indent + d.indent, mode: c.mode, doc: d.doc})
+		case group:
+			m := c.mode
+
+			if d.hard {
+				m = broken
+			}
+
+			cmds = append(cmds, command{indent: c.indent, mode: m, doc: d.doc})
+		}
+	}
+
+	return false
+}
+
+// String lays out the given Doc with Render, returning the result as a string.
+func String(width int, d Doc) string {
+	var sb strings.Builder
+
+	Render(&sb, width, d)
+
+	return sb.String()
+}
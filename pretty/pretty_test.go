@@ -0,0 +1,264 @@
+package pretty
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"vimagination.zapto.org/parser"
+)
+
+func TestRender(t *testing.T) {
+	call := func(name string, args ...Doc) Doc {
+		return Group(Text(name), Text("("), Nest(4, SoftLine(), Join(Concat(Text(","), Line()), args...)), SoftLine(), Text(")"))
+	}
+
+	for n, test := range [...]struct {
+		Doc    Doc
+		Width  int
+		Output string
+	}{
+		{
+			Doc:    Text("hello"),
+			Width:  80,
+			Output: "hello",
+		},
+		{
+			Doc:    Group(Text("a"), Line(), Text("b")),
+			Width:  80,
+			Output: "a b",
+		},
+		{
+			Doc:    Group(Text("a"), Line(), Text("b")),
+			Width:  2,
+			Output: "a\nb",
+		},
+		{
+			Doc:    Group(Text("a"), SoftLine(), Text("b")),
+			Width:  80,
+			Output: "ab",
+		},
+		{
+			Doc:    Group(Text("a"), Line(), Text("b"), HardLine(), Text("c")),
+			Width:  80,
+			Output: "a\nb\nc",
+		},
+		{
+			Doc:    call("f", Text("a"), Text("b")),
+			Width:  80,
+			Output: "f(a, b)",
+		},
+		{
+			Doc:    call("f", Text("alpha"), Text("beta")),
+			Width:  10,
+			Output: "f(\n    alpha,\n    beta\n)",
+		},
+		{
+			Doc:    call("f", call("g", Text("alpha")), call("h", Text("beta"))),
+			Width:  14,
+			Output: "f(\n    g(alpha),\n    h(beta)\n)",
+		},
+		{
+			Doc:    Concat(call("f", Text("a")), Text("; trailing text")),
+			Width:  10,
+			Output: "f(\n    a\n); trailing text",
+		},
+		{
+			Doc:    Nest(2, Text("a"), HardLine(), HardLine(), Text("b")),
+			Width:  80,
+			Output: "a\n\n  b",
+		},
+		{
+			Doc:    Group(Text("ünïcödé"), Line(), Text("b")),
+			Width:  9,
+			Output: "ünïcödé b",
+		},
+		{
+			Doc:    Group(Text("averylongword"), Line(), Text("b")),
+			Width:  5,
+			Output: "averylongword\nb",
+		},
+	} {
+		if output := String(test.Width, test.Doc); output != test.Output {
+			t.Errorf("test %d: expecting output %q, got %q", n+1, test.Output, output)
+		}
+	}
+}
+
+type errWriter struct{}
+
+var errWrite = errors.New("write error")
+
+func (errWriter) Write([]byte) (int, error) {
+	return 0, errWrite
+}
+
+func TestRenderError(t *testing.T) {
+	if err := Render(errWriter{}, 80, Text("a")); !errors.Is(err, errWrite) {
+		t.Errorf("expecting error %v, got %v", errWrite, err)
+	}
+}
+
+const (
+	tokenWord parser.TokenType = iota
+	tokenOpen
+	tokenClose
+)
+
+const (
+	phraseList parser.PhraseType = iota
+	phraseStatement
+)
+
+func tokeniser(t *parser.Tokeniser) (parser.Token, parser.TokenFunc) {
+	if t.AcceptRun(" \n"); t.Len() > 0 {
+		t.Get()
+	}
+
+	switch {
+	case t.Peek() == -1:
+		return t.Done()
+	case t.Accept("("):
+		return t.Return(tokenOpen, tokeniser)
+	case t.Accept(")"):
+		return t.Return(tokenClose, tokeniser)
+	}
+
+	t.ExceptRun("() \n")
+
+	return t.Return(tokenWord, tokeniser)
+}
+
+func phraser(p *parser.Parser) (parser.Phrase, parser.PhraseFunc) {
+	if p.Peek().Type == parser.TokenDone {
+		return p.Done()
+	}
+
+	depth := 0
+
+	for {
+		switch p.Next().Type {
+		case tokenOpen:
+			p.Backup(1)
+			p.OpenNode(phraseList)
+			p.Next()
+
+			depth++
+		case tokenClose:
+			p.CloseNode()
+
+			depth--
+		case parser.TokenDone:
+			return p.Return(phraseStatement, phraser)
+		}
+
+		if depth == 0 {
+			return p.Return(phraseStatement, phraser)
+		}
+	}
+}
+
+func TestFormatter(t *testing.T) {
+	f := Formatter{
+		Space: func(prev, next parser.Token) Doc {
+			if prev.Type == tokenOpen || next.Type == tokenClose {
+				return nil
+			}
+
+			return Line()
+		},
+		Nodes: map[parser.PhraseType]NodeFunc{
+			phraseList: func(n *parser.Node, children []Doc) Doc {
+				return Group(children[0], Nest(1, Join(Line(), children[1:len(children)-1]...)), children[len(children)-1])
+			},
+		},
+	}
+
+	for n, test := range [...]struct {
+		Input  string
+		Width  int
+		Output string
+	}{
+		{
+			Input:  "a   b\n(c   d)",
+			Width:  80,
+			Output: "a\nb\n(c d)",
+		},
+		{
+			Input:  "(define (square x) (* x x))",
+			Width:  80,
+			Output: "(define (square x) (* x x))",
+		},
+		{
+			Input:  "(define (square x) (* x x))",
+			Width:  20,
+			Output: "(define\n (square x)\n (* x x))",
+		},
+		{
+			Input:  "(define (square x) (* x x))",
+			Width:  8,
+			Output: "(define\n (square\n  x)\n (*\n  x\n  x))",
+		},
+	} {
+		p := parser.New(parser.NewStringTokeniser(test.Input))
+
+		p.TokeniserState(tokeniser)
+		p.PhraserState(phraser)
+		p.TreeMode(true)
+
+		d, err := f.Parse(&p)
+		if err != nil {
+			t.Errorf("test %d: unexpected error: %s", n+1, err)
+		} else if output := String(test.Width, d); output != test.Output {
+			t.Errorf("test %d: expecting output %q, got %q", n+1, test.Output, output)
+		}
+	}
+}
+
+func TestFormatterTokens(t *testing.T) {
+	f := Formatter{
+		Token: func(tk parser.Token) Doc {
+			return Text(strings.ToUpper(tk.Data))
+		},
+		Space: func(_, _ parser.Token) Doc {
+			return Text(" ")
+		},
+	}
+
+	tokens := []parser.Token{
+		{Type: tokenWord, Data: "a"},
+		{Type: tokenWord, Data: "b"},
+		{Type: parser.TokenDone},
+	}
+
+	if output := String(80, f.Tokens(tokens)); output != "A B" {
+		t.Errorf("expecting output %q, got %q", "A B", output)
+	}
+}
+
+func TestFormatterNodeBounds(t *testing.T) {
+	f := Formatter{
+		Space: func(prev, next parser.Token) Doc {
+			return Text("<" + prev.Data + next.Data + ">")
+		},
+	}
+
+	var (
+		a    = parser.Child{Token: parser.Token{Type: tokenWord, Data: "a"}}
+		b    = parser.Child{Token: parser.Token{Type: tokenWord, Data: "b"}}
+		c    = parser.Child{Token: parser.Token{Type: tokenWord, Data: "c"}}
+		done = parser.Child{Token: parser.Token{Type: parser.TokenDone}}
+	)
+
+	root := &parser.Node{
+		Children: []parser.Child{
+			{Node: &parser.Node{Children: []parser.Child{a, {Node: &parser.Node{Children: []parser.Child{b, done}}}}}},
+			{Node: &parser.Node{}},
+			{Node: &parser.Node{Children: []parser.Child{done, {Node: &parser.Node{Children: []parser.Child{done, c}}}}}},
+		},
+	}
+
+	if output := String(80, f.Node(root)); output != "a<ab>b<bc>c" {
+		t.Errorf("expecting output %q, got %q", "a<ab>b<bc>c", output)
+	}
+}
@@ -0,0 +1,256 @@
+package parser
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Escapes is a set of flags that determines which escape sequences are
+// accepted by Tokeniser.AcceptQuoted.
+type Escapes uint8
+
+// Escape sequence flags.
+const (
+	// EscapeSimple accepts \a, \b, \f, \n, \r, \t and \v, and the escape
+	// character or a quote character following the escape character.
+	EscapeSimple Escapes = 1 << iota
+
+	// EscapeHex accepts \xHH, which produces a single byte.
+	EscapeHex
+
+	// EscapeUnicode accepts \uHHHH, which produces a Unicode code point.
+	EscapeUnicode
+
+	// EscapeLongUnicode accepts \UHHHHHHHH, which produces a Unicode code
+	// point.
+	EscapeLongUnicode
+
+	// EscapeOctal accepts up to three octal digits, with a value up to 0377,
+	// which produces a single byte.
+	EscapeOctal
+
+	// EscapeNewline accepts the escape character followed by a newline, which
+	// produces nothing, allowing a string to be continued on the next line.
+	EscapeNewline
+
+	// EscapeOther accepts the escape character followed by any other
+	// character, which produces that character.
+	EscapeOther
+)
+
+// QuoteOptions determines which forms of quoted string are accepted by
+// Tokeniser.AcceptQuoted.
+type QuoteOptions struct {
+	// Quotes contains the characters that can begin and end a string in
+	// which escape sequences are processed.
+	Quotes string
+
+	// RawQuotes contains the characters that can begin and end a raw string,
+	// in which no escape sequences are processed.
+	RawQuotes string
+
+	// Escape is the character that begins an escape sequence, such as '\\';
+	// if zero, no escape sequences are processed.
+	Escape rune
+
+	// Escapes determines which escape sequences are accepted.
+	Escapes Escapes
+
+	// Doubled allows a quote character to be included in a string by
+	// doubling it, as in SQL and CSV.
+	Doubled bool
+
+	// Multiline allows strings, other than raw strings, to contain newlines.
+	Multiline bool
+
+	// RawMultiline allows raw strings to contain newlines.
+	RawMultiline bool
+}
+
+// Quoted is a quoted string read by Tokeniser.AcceptQuoted.
+type Quoted struct {
+	// Text is the string as it was read, including its quotes.
+	Text string
+
+	// Value is the decoded contents of the string.
+	Value string
+
+	// Quote is the character that began and ended the string.
+	Quote rune
+
+	// Raw is true when the string was a raw string.
+	Raw bool
+}
+
+var simpleEscapes = map[rune]rune{
+	'a': '\a',
+	'b': '\b',
+	'f': '\f',
+	'n': '\n',
+	'r': '\r',
+	't': '\t',
+	'v': '\v',
+}
+
+// AcceptQuoted reads a quoted string, in one of the forms enabled by the given
+// options, returning both the string as read and its decoded value.
+//
+// If the next character is not a quote character, no characters are read and
+// an error wrapping ErrNotQuoted is returned. Otherwise, when the string is
+// malformed, the characters up to the problem are read, and a *SyntaxError is
+// returned; for an invalid escape sequence, the offset is that of the escape
+// character that began it.
+func (t *Tokeniser) AcceptQuoted(opts QuoteOptions) (Quoted, error) {
+	var (
+		q           Quoted
+		text, value strings.Builder
+	)
+
+	switch r := t.Peek(); {
+	case r >= 0 && strings.ContainsRune(opts.RawQuotes, r):
+		q.Raw = true
+	case r >= 0 && strings.ContainsRune(opts.Quotes, r):
+	default:
+		return q, t.syntaxError(ErrNotQuoted)
+	}
+
+	q.Quote = t.Next()
+
+	text.WriteRune(q.Quote)
+
+	for {
+		offset := t.offset + t.Len()
+
+		switch c := t.Next(); {
+		case c == -1:
+			return q, t.syntaxError(ErrUnterminatedString)
+		case c == q.Quote:
+			text.WriteRune(c)
+
+			if opts.Doubled && t.AcceptRune(q.Quote) {
+				text.WriteRune(c)
+				value.WriteRune(c)
+
+				continue
+			}
+
+			q.Text = text.String()
+			q.Value = value.String()
+
+			return q, nil
+		case c == '\n' && !(q.Raw && opts.RawMultiline || !q.Raw && opts.Multiline):
+			t.backup()
+
+			return q, t.syntaxError(ErrNewlineInString)
+		case c == opts.Escape && opts.Escape != 0 && !q.Raw:
+			text.WriteRune(c)
+
+			if err := t.acceptEscape(&text, &value, opts, offset); err != nil {
+				return q, err
+			}
+		default:
+			text.WriteRune(c)
+			value.WriteRune(c)
+		}
+	}
+}
+
+func (t *Tokeniser) acceptEscape(text, value *strings.Builder, opts QuoteOptions, offset int) error {
+	c := t.Next()
+	if c == -1 {
+		return t.syntaxError(ErrUnterminatedString)
+	}
+
+	text.WriteRune(c)
+
+	if opts.Escapes&EscapeSimple != 0 {
+		if r, ok := simpleEscapes[c]; ok {
+			value.WriteRune(r)
+
+			return nil
+		} else if c == opts.Escape || strings.ContainsRune(opts.Quotes, c) {
+			value.WriteRune(c)
+
+			return nil
+		}
+	}
+
+	switch {
+	case c == 'x' && opts.Escapes&EscapeHex != 0:
+		b, ok := t.acceptHexDigits(text, 2)
+		if !ok {
+			return &SyntaxError{Err: ErrInvalidEscape, Offset: offset}
+		}
+
+		value.WriteByte(byte(b))
+	case c == 'u' && opts.Escapes&EscapeUnicode != 0, c == 'U' && opts.Escapes&EscapeLongUnicode != 0:
+		digits := 4
+
+		if c == 'U' {
+			digits = 8
+		}
+
+		r, ok := t.acceptHexDigits(text, digits)
+		if !ok {
+			return &SyntaxError{Err: ErrInvalidEscape, Offset: offset}
+		} else if !utf8.ValidRune(rune(r)) {
+			return &SyntaxError{Err: ErrEscapeRange, Offset: offset}
+		}
+
+		value.WriteRune(rune(r))
+	case c >= '0' && c <= '7' && opts.Escapes&EscapeOctal != 0:
+		b := uint32(c - '0')
+
+		for range 2 {
+			r := t.Peek()
+			if !isDigit(r, 8) {
+				break
+			}
+
+			text.WriteRune(t.Next())
+
+			b = b*8 + uint32(r-'0')
+		}
+
+		if b > 0377 {
+			return &SyntaxError{Err: ErrEscapeRange, Offset: offset}
+		}
+
+		value.WriteByte(byte(b))
+	case c == '\n' && opts.Escapes&EscapeNewline != 0:
+	case opts.Escapes&EscapeOther != 0:
+		value.WriteRune(c)
+	default:
+		return &SyntaxError{Err: ErrInvalidEscape, Offset: offset}
+	}
+
+	return nil
+}
+
+func (t *Tokeniser) acceptHexDigits(text *strings.Builder, digits int) (uint32, bool) {
+	var n uint32
+
+	for range digits {
+		r := t.Peek()
+		if !isDigit(r, 16) {
+			return n, false
+		}
+
+		text.WriteRune(t.Next())
+
+		n = n<<4 | uint32(digitValue(r))
+	}
+
+	return n, true
+}
+
+func digitValue(r rune) int {
+	switch {
+	case r >= 'a':
+		return int(r-'a') + 10
+	case r >= 'A':
+		return int(r-'A') + 10
+	}
+
+	return int(r - '0')
+}
@@ -0,0 +1,138 @@
+package parser
+
+import (
+	"errors"
+	"testing"
+)
+
+var goStrings = QuoteOptions{
+	Quotes:       "\"'",
+	RawQuotes:    "`",
+	Escape:       '\\',
+	Escapes:      EscapeSimple | EscapeHex | EscapeUnicode | EscapeLongUnicode | EscapeOctal,
+	RawMultiline: true,
+}
+
+func TestAcceptQuoted(t *testing.T) {
+	for n, test := range [...]struct {
+		Input   string
+		Options QuoteOptions
+		Quoted  Quoted
+		Err     error
+		Offset  int
+	}{
+		{
+			Input:   `"abc" rest`,
+			Options: goStrings,
+			Quoted:  Quoted{Text: `"abc"`, Value: "abc", Quote: '"'},
+		},
+		{
+			Input:   `'a\tb\\c\'d\"'`,
+			Options: goStrings,
+			Quoted:  Quoted{Text: `'a\tb\\c\'d\"'`, Value: "a\tb\\c'd\"", Quote: '\''},
+		},
+		{
+			Input:   `"\x41é\U0001F600\101\0"`,
+			Options: goStrings,
+			Quoted:  Quoted{Text: `"\x41é\U0001F600\101\0"`, Value: "Aé😀A\x00", Quote: '"'},
+		},
+		{
+			Input:   "`raw\\n\nstring`",
+			Options: goStrings,
+			Quoted:  Quoted{Text: "`raw\\n\nstring`", Value: "raw\\n\nstring", Quote: '`', Raw: true},
+		},
+		{
+			Input:   `'it''s'`,
+			Options: QuoteOptions{Quotes: "'", Doubled: true},
+			Quoted:  Quoted{Text: `'it''s'`, Value: "it's", Quote: '\''},
+		},
+		{
+			Input:   "\"a\\\nb\"",
+			Options: QuoteOptions{Quotes: "\"", Escape: '\\', Escapes: EscapeNewline},
+			Quoted:  Quoted{Text: "\"a\\\nb\"", Value: "ab", Quote: '"'},
+		},
+		{
+			Input:   "\"a\nb\"",
+			Options: QuoteOptions{Quotes: "\"", Multiline: true},
+			Quoted:  Quoted{Text: "\"a\nb\"", Value: "a\nb", Quote: '"'},
+		},
+		{
+			Input:   `"a\qb"`,
+			Options: QuoteOptions{Quotes: "\"", Escape: '\\', Escapes: EscapeOther},
+			Quoted:  Quoted{Text: `"a\qb"`, Value: "aqb", Quote: '"'},
+		},
+		{
+			Input:   "abc",
+			Options: goStrings,
+			Err:     ErrNotQuoted,
+		},
+		{
+			Input:   `"abc`,
+			Options: goStrings,
+			Err:     ErrUnterminatedString,
+			Offset:  4,
+		},
+		{
+			Input:   "\"ab\ncd\"",
+			Options: goStrings,
+			Err:     ErrNewlineInString,
+			Offset:  3,
+		},
+		{
+			Input:   `"ab\qcd"`,
+			Options: goStrings,
+			Err:     ErrInvalidEscape,
+			Offset:  3,
+		},
+		{
+			Input:   `"a\x4g"`,
+			Options: goStrings,
+			Err:     ErrInvalidEscape,
+			Offset:  2,
+		},
+		{
+			Input:   `"abc\uD800"`,
+			Options: goStrings,
+			Err:     ErrEscapeRange,
+			Offset:  4,
+		},
+		{
+			Input:   `"\400"`,
+			Options: goStrings,
+			Err:     ErrEscapeRange,
+			Offset:  1,
+		},
+		{
+			Input:   `"\x41"`,
+			Options: QuoteOptions{Quotes: "\"", Escape: '\\', Escapes: EscapeSimple},
+			Err:     ErrInvalidEscape,
+			Offset:  1,
+		},
+		{
+			Input:   `"ab\`,
+			Options: goStrings,
+			Err:     ErrUnterminatedString,
+			Offset:  4,
+		},
+	} {
+		for backend, tk := range tokenisers(test.Input) {
+			q, err := tk.AcceptQuoted(test.Options)
+
+			var se *SyntaxError
+
+			if !errors.Is(err, test.Err) {
+				t.Errorf("test %d (%s): expecting error %v, got %v", n+1, backend, test.Err, err)
+			} else if err != nil {
+				if !errors.As(err, &se) {
+					t.Errorf("test %d (%s): expecting SyntaxError, got %T", n+1, backend, err)
+				} else if se.Offset != test.Offset {
+					t.Errorf("test %d (%s): expecting offset %d, got %d", n+1, backend, test.Offset, se.Offset)
+				}
+			} else if q != test.Quoted {
+				t.Errorf("test %d (%s): expecting %#v, got %#v", n+1, backend, test.Quoted, q)
+			} else if read := tk.Get(); read != q.Text {
+				t.Errorf("test %d (%s): expecting to read %q, got %q", n+1, backend, q.Text, read)
+			}
+		}
+	}
+}
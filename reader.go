@@ -74,6 +74,8 @@ func (r *readerParser) sub() tokeniser {
 		tokeniser: r,
 		tState:    r.stateNum,
 		start:     r.pos,
+		offset:    r.length(),
+		mark:      r.state(),
 	}
 }
 
@@ -0,0 +1,66 @@
+package parser
+
+// Diagnostic records an error from which the Parser recovered.
+//
+// Offset is the byte offset, within the input, of the next Token at the time
+// of the error.
+type Diagnostic struct {
+	Err    error
+	Offset int
+}
+
+// Recover enables panic-mode error recovery.
+//
+// When a PhraseFunc returns a PhraseError Phrase, the error is recorded as a
+// Diagnostic, and Tokens are skipped up to and including the next Token with
+// one of the given synchronisation types. The skipped Tokens, along with those
+// read by the failing PhraseFunc, are returned as a PhraseSkipped Phrase, with
+// a nil error, and parsing resumes with the given PhraseFunc.
+//
+// When no synchronisation types are given, only the Tokens read by the failing
+// PhraseFunc are skipped or, if it read none, the next Token.
+//
+// Errors from the Tokeniser, and errors at the end of the input when there are
+// no Tokens to skip, are not recovered from.
+//
+// Calling Recover with a nil PhraseFunc disables recovery.
+func (p *Parser) Recover(resume PhraseFunc, sync ...TokenType) {
+	p.resume = resume
+	p.sync = sync
+}
+
+// Diagnostics returns the errors that have been recovered from.
+func (p *Parser) Diagnostics() []Diagnostic {
+	return p.diagnostics
+}
+
+func (p *Parser) recover() (Phrase, bool) {
+	if p.resume == nil || p.finished() && p.tokens[len(p.tokens)-1].Type == TokenError {
+		return Phrase{}, false
+	}
+
+	d := Diagnostic{
+		Err:    p.Err,
+		Offset: p.Offset(),
+	}
+
+	if len(p.sync) == 0 {
+		if p.Len() == 0 && p.Peek().Type >= 0 {
+			p.Next()
+		}
+	} else {
+		p.ExceptRun(p.sync...)
+		p.Accept(p.sync...)
+	}
+
+	if p.Len() == 0 {
+		return Phrase{}, false
+	}
+
+	p.Err = nil
+	p.diagnostics = append(p.diagnostics, d)
+	ph, _ := p.Return(PhraseSkipped, nil)
+	p.state = p.resume
+
+	return ph, true
+}
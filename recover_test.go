@@ -0,0 +1,120 @@
+package parser
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+)
+
+const recoverStatement PhraseType = iota
+
+func recoverParser(p *Parser) (Phrase, PhraseFunc) {
+	if p.Peek().Type == TokenDone {
+		return p.Done()
+	}
+
+	for _, types := range [...][]TokenType{{fixtureWord}, {fixtureAssign}, {fixtureWord, fixtureNumber}, {fixtureSemi}} {
+		if _, err := p.Expect(types...); err != nil {
+			return p.ReturnError(err)
+		}
+	}
+
+	return p.Return(recoverStatement, recoverParser)
+}
+
+func TestRecover(t *testing.T) {
+	p := fixtureParser("a = 1; b = ; c = 2; d e = f; g")
+
+	p.PhraserState(recoverParser)
+	p.Recover(recoverParser, fixtureSemi)
+
+	var (
+		word   = func(data string) Token { return Token{Type: fixtureWord, Data: data} }
+		number = func(data string) Token { return Token{Type: fixtureNumber, Data: data} }
+		assign = Token{Type: fixtureAssign, Data: "="}
+		semi   = Token{Type: fixtureSemi, Data: ";"}
+	)
+
+	for n, expected := range [...]Phrase{
+		{Type: recoverStatement, Data: []Token{word("a"), assign, number("1"), semi}, Span: Span{Start: 0, End: 6}},
+		{Type: PhraseSkipped, Data: []Token{word("b"), assign, semi}, Span: Span{Start: 6, End: 12}},
+		{Type: recoverStatement, Data: []Token{word("c"), assign, number("2"), semi}, Span: Span{Start: 12, End: 19}},
+		{Type: PhraseSkipped, Data: []Token{word("d"), word("e"), assign, word("f"), semi}, Span: Span{Start: 19, End: 28}},
+		{Type: PhraseSkipped, Data: []Token{word("g")}, Span: Span{Start: 28, End: 30}},
+	} {
+		if ph, err := p.GetPhrase(); err != nil {
+			t.Fatalf("test %d: unexpected error: %s", n+1, err)
+		} else if !reflect.DeepEqual(ph, expected) {
+			t.Errorf("test %d: expecting phrase %v, got %v", n+1, expected, ph)
+		}
+	}
+
+	if ph, err := p.GetPhrase(); err != nil || ph.Type != PhraseDone {
+		t.Errorf("test 6: expecting PhraseDone, got %v, %v", ph, err)
+	} else if _, err := p.GetPhrase(); !errors.Is(err, io.EOF) {
+		t.Errorf("test 6: expecting io.EOF, got %v", err)
+	}
+
+	diagnostics := p.Diagnostics()
+
+	if len(diagnostics) != 3 {
+		t.Fatalf("test 7: expecting 3 diagnostics, got %d", len(diagnostics))
+	}
+
+	for n, expected := range [...]Diagnostic{
+		{Err: &UnexpectedTokenError{Expected: []string{"word", "number"}, Token: semi, Name: "semicolon", Offset: 11}, Offset: 11},
+		{Err: &UnexpectedTokenError{Expected: []string{"assign"}, Token: word("e"), Name: "word", Offset: 22}, Offset: 22},
+		{Err: &UnexpectedTokenError{Expected: []string{"assign"}, Token: Token{Type: TokenDone, Data: ""}, Name: "TokenDone", Offset: 30}, Offset: 30},
+	} {
+		if !reflect.DeepEqual(diagnostics[n], expected) {
+			t.Errorf("test %d: expecting diagnostic %v, got %v", n+8, expected, diagnostics[n])
+		}
+	}
+}
+
+func TestRecoverTokeniserError(t *testing.T) {
+	p := fixtureParser("a = !")
+
+	p.PhraserState(recoverParser)
+	p.Recover(recoverParser, fixtureSemi)
+
+	if _, err := p.GetPhrase(); !errors.Is(err, ErrUnknownError) {
+		t.Errorf("expecting ErrUnknownError, got %v", err)
+	} else if len(p.Diagnostics()) != 0 {
+		t.Errorf("expecting no diagnostics, got %v", p.Diagnostics())
+	}
+}
+
+func TestRecoverNoSync(t *testing.T) {
+	p := fixtureParser("a = 1; ; b c = 2;")
+
+	p.PhraserState(recoverParser)
+	p.Recover(recoverParser)
+
+	var (
+		word   = func(data string) Token { return Token{Type: fixtureWord, Data: data} }
+		number = func(data string) Token { return Token{Type: fixtureNumber, Data: data} }
+		assign = Token{Type: fixtureAssign, Data: "="}
+		semi   = Token{Type: fixtureSemi, Data: ";"}
+	)
+
+	for n, expected := range [...]Phrase{
+		{Type: recoverStatement, Data: []Token{word("a"), assign, number("1"), semi}, Span: Span{Start: 0, End: 6}},
+		{Type: PhraseSkipped, Data: []Token{semi}, Span: Span{Start: 6, End: 8}},
+		{Type: PhraseSkipped, Data: []Token{word("b")}, Span: Span{Start: 8, End: 10}},
+		{Type: recoverStatement, Data: []Token{word("c"), assign, number("2"), semi}, Span: Span{Start: 10, End: 17}},
+	} {
+		if ph, err := p.GetPhrase(); err != nil {
+			t.Fatalf("test %d: unexpected error: %s", n+1, err)
+		} else if !reflect.DeepEqual(ph, expected) {
+			t.Errorf("test %d: expecting phrase %v, got %v", n+1, expected, ph)
+		}
+	}
+
+	if ph, err := p.GetPhrase(); err != nil || ph.Type != PhraseDone {
+		t.Errorf("test 5: expecting PhraseDone, got %v, %v", ph, err)
+	} else if len(p.Diagnostics()) != 2 {
+		t.Errorf("test 6: expecting 2 diagnostics, got %d", len(p.Diagnostics()))
+	}
+}
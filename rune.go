@@ -67,6 +67,8 @@ func (r *runeSourceParser) sub() tokeniser {
 		tokeniser: r,
 		tState:    r.stateNum,
 		start:     r.pos,
+		offset:    r.length(),
+		mark:      r.state(),
 	}
 }
 
@@ -55,6 +55,8 @@ func (p *strParser) sub() tokeniser {
 		tokeniser: p,
 		tState:    len(p.str),
 		start:     p.pos,
+		offset:    p.pos,
+		mark:      p.state(),
 	}
 }
 
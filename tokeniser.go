@@ -18,6 +18,7 @@ type TokenType int
 const (
 	TokenDone TokenType = -1 - iota
 	TokenError
+	tokenContinue
 )
 
 // Token represents data parsed from the stream.
@@ -30,12 +31,15 @@ type Token struct {
 // the tokeniser.
 type TokenFunc func(*Tokeniser) (Token, TokenFunc)
 
-// State represents a position in the byte stream of the Tokeniser.
+// State represents a position in the byte stream of the Tokeniser, or in the
+// Token stream of a Parser.
 type State interface {
-	// Reset returns the byte stream to the position it was in when this
-	// object was created.
+	// Reset returns the stream to the position it was in when this object
+	// was created, returning false if that is no longer possible.
 	//
-	// Only valid until Tokeniser.Get is called.
+	// For a Tokeniser, only valid until Tokeniser.Get is called; for a
+	// Parser, only valid until Parser.Get is called after reading past the
+	// position.
 	Reset() bool
 }
 
@@ -53,8 +57,19 @@ type tokeniser interface {
 // Tokeniser is a state machine to generate tokens from an input.
 type Tokeniser struct {
 	tokeniser
-	Err   error
-	state TokenFunc
+	Err    error
+	state  TokenFunc
+	offset int
+	end    int
+	queue  []queued
+
+	heredocs []Heredoc
+	pipe     *pipeline
+}
+
+type queued struct {
+	token Token
+	end   int
 }
 
 // GetToken runs the state machine and retrieves a single token and possible an
@@ -71,9 +86,17 @@ func (t *Tokeniser) GetToken() (Token, error) {
 
 // Iter yields each token as it's returned, stopping after yielding a TokenDone
 // or TokenError Token.
+//
+// Stopping early stops any pipeline started with Pipeline.
 func (t *Tokeniser) Iter(yield func(Token) bool) {
 	for {
-		if tk := t.get(); !yield(tk) || tk.Type == TokenDone || tk.Type == TokenError {
+		tk := t.get()
+
+		if !yield(tk) {
+			t.StopPipeline()
+
+			break
+		} else if tk.Type == TokenDone || tk.Type == TokenError {
 			break
 		}
 	}
@@ -90,27 +113,88 @@ func (t *Tokeniser) TokeniserState(tf TokenFunc) {
 }
 
 func (t *Tokeniser) get() Token {
-	if errors.Is(t.Err, io.EOF) {
-		return Token{
-			Type: TokenDone,
-			Data: "",
-		}
+	if t.pipe != nil {
+		return t.pipe.get(t)
 	}
 
-	if t.state == nil {
-		t.Err = ErrNoState
-		t.state = (*Tokeniser).Error
+	for {
+		if len(t.queue) > 0 {
+			q := t.queue[0]
+			t.queue = t.queue[1:]
+			t.end = q.end
+
+			return q.token
+		}
+
+		if errors.Is(t.Err, io.EOF) {
+			t.end = t.offset
+
+			return Token{
+				Type: TokenDone,
+				Data: "",
+			}
+		}
+
+		if t.state == nil {
+			t.Err = ErrNoState
+			t.state = (*Tokeniser).Error
+		}
+
+		var tk Token
+
+		tk, t.state = t.state(t)
+
+		if tk.Type == tokenContinue {
+			continue
+		}
+
+		if tk.Type == TokenError && errors.Is(t.Err, io.EOF) {
+			t.Err = io.ErrUnexpectedEOF
+		}
+
+		if len(t.queue) > 0 {
+			t.EmitToken(tk)
+
+			continue
+		}
+
+		t.end = t.offset
+
+		return tk
 	}
+}
 
-	var tk Token
+// Emit adds a Token of the given type, with the data read since the last
+// call to Get, to the queue of pending Tokens.
+//
+// Pending Tokens are returned, in order, before any Token returned by the
+// TokenFunc that queued them, and before the next TokenFunc is called.
+func (t *Tokeniser) Emit(typ TokenType) {
+	t.EmitToken(Token{
+		Type: typ,
+		Data: t.Get(),
+	})
+}
 
-	tk, t.state = t.state(t)
+// EmitToken adds the given Token to the queue of pending Tokens; see Emit.
+//
+// This can be used to queue synthetic Tokens that do not correspond to any
+// data read.
+func (t *Tokeniser) EmitToken(tk Token) {
+	t.queue = append(t.queue, queued{token: tk, end: t.offset})
+}
 
-	if tk.Type == TokenError && errors.Is(t.Err, io.EOF) {
-		t.Err = io.ErrUnexpectedEOF
+// Continue returns from a TokenFunc without returning a Token, so that the
+// pending Tokens, if any, are returned before the given TokenFunc is called.
+//
+// This allows a TokenFunc to return any number of Tokens, including none. The
+// next TokenFunc defaults to Done.
+func (t *Tokeniser) Continue(fn TokenFunc) (Token, TokenFunc) {
+	if fn == nil {
+		fn = (*Tokeniser).Done
 	}
 
-	return tk
+	return Token{Type: tokenContinue}, fn
 }
 
 // Accept returns true if the next character to be read is contained within the
@@ -160,7 +244,16 @@ func (t *Tokeniser) Peek() rune {
 // Get returns a string of everything that has been read so far and resets
 // the string for the next round of parsing.
 func (t *Tokeniser) Get() string {
-	return t.tokeniser.get()
+	str := t.tokeniser.get()
+	t.offset += len(str)
+
+	return str
+}
+
+// Offset returns the number of bytes that have been returned by Get, which is
+// the offset within the input of the data currently being read.
+func (t *Tokeniser) Offset() int {
+	return t.offset
 }
 
 // Len returns the number of bytes that has been read since the last Get.
@@ -297,9 +390,13 @@ func (t *Tokeniser) State() State {
 //
 // This allows the sub-tokenisers Get method to be called without calling it on
 // its parent.
+//
+// The Len and Reset methods of the sub-tokeniser work relative to its last call
+// to Get, or to when it was created, rather than to those of its parent.
 func (t *Tokeniser) SubTokeniser() *Tokeniser {
 	return &Tokeniser{
 		tokeniser: t.tokeniser.sub(),
+		offset:    t.offset + t.Len(),
 	}
 }
 
@@ -369,7 +466,8 @@ func (t *Tokeniser) Error() (Token, TokenFunc) {
 
 type sub struct {
 	tokeniser
-	tState, start int
+	tState, start, offset int
+	mark                  State
 }
 
 func (s *sub) get() string {
@@ -380,12 +478,49 @@ func (s *sub) get() string {
 	var str string
 
 	str, s.start = s.slice(s.tState, s.start)
+	s.offset = s.tokeniser.length()
+	s.mark = s.tokeniser.state()
 
 	return str
 }
 
+func (s *sub) reset() {
+	if s.start >= 0 {
+		s.mark.Reset()
+	}
+}
+
+func (s *sub) length() int {
+	if s.start < 0 {
+		return 0
+	}
+
+	return max(s.tokeniser.length()-s.offset, 0)
+}
+
 // Errors.
 var (
-	ErrNoState      = errors.New("no state")
-	ErrUnknownError = errors.New("unknown error")
+	ErrNoState             = errors.New("no state")
+	ErrUnknownError        = errors.New("unknown error")
+	ErrUnexpectedToken     = errors.New("unexpected token")
+	ErrNotNumber           = errors.New("not a number")
+	ErrMissingDigits       = errors.New("missing digits")
+	ErrInvalidDigit        = errors.New("invalid digit")
+	ErrInvalidSeparator    = errors.New("invalid digit separator")
+	ErrMissingExponent     = errors.New("missing exponent")
+	ErrInvalidSuffix       = errors.New("invalid suffix")
+	ErrNotInteger          = errors.New("not an integer")
+	ErrOverflow            = errors.New("value out of range")
+	ErrNotQuoted           = errors.New("not a quoted string")
+	ErrUnterminatedString  = errors.New("unterminated string")
+	ErrNewlineInString     = errors.New("newline in string")
+	ErrInvalidEscape       = errors.New("invalid escape sequence")
+	ErrEscapeRange         = errors.New("escape sequence value out of range")
+	ErrUnterminatedComment = errors.New("unterminated comment")
+	ErrInconsistentIndent  = errors.New("inconsistent use of tabs and spaces in indentation")
+	ErrInconsistentDedent  = errors.New("unindent does not match any outer indentation level")
+	ErrNotHeredoc          = errors.New("not a heredoc")
+	ErrMissingDelimiter    = errors.New("missing heredoc delimiter")
+	ErrNoHeredoc           = errors.New("no pending heredoc")
+	ErrNoEqual             = errors.New("no Equal func given for Boundary")
 )
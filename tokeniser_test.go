@@ -2,6 +2,7 @@ package parser
 
 import (
 	"iter"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -372,3 +373,144 @@ func TestTokeniserSub(t *testing.T) {
 		}
 	}
 }
+
+func TestTokeniserSubLen(t *testing.T) {
+	for n, p := range tokenisers("ABCDE") {
+		p.Next()
+
+		q := p.SubTokeniser()
+
+		if l := q.Len(); l != 0 {
+			t.Errorf("test 1 (%s): expecting to have read 0 bytes, read %d", n, l)
+		}
+
+		q.Next()
+
+		if l := q.Len(); l != 1 {
+			t.Errorf("test 2 (%s): expecting to have read 1 byte, read %d", n, l)
+		} else if got := q.Get(); got != "B" {
+			t.Errorf("test 3 (%s): expecting to read %q, got %q", n, "B", got)
+		} else if l := q.Len(); l != 0 {
+			t.Errorf("test 4 (%s): expecting to have read 0 bytes, read %d", n, l)
+		}
+
+		q.ExceptRun("")
+
+		if l := q.Len(); l != 3 {
+			t.Errorf("test 5 (%s): expecting to have read 3 bytes, read %d", n, l)
+		} else if l := p.Len(); l != 5 {
+			t.Errorf("test 6 (%s): expecting parent to have read 5 bytes, read %d", n, l)
+		}
+	}
+}
+
+func TestTokeniserSubReset(t *testing.T) {
+	for n, p := range tokenisers("ABCDE") {
+		p.Next()
+
+		q := p.SubTokeniser()
+
+		q.Next()
+		q.Next()
+		q.Reset()
+
+		if c := q.Peek(); c != 'B' {
+			t.Errorf("test 1 (%s): expecting to read %q, got %q", n, 'B', c)
+		} else if l := p.Len(); l != 1 {
+			t.Errorf("test 2 (%s): expecting parent to have read 1 byte, read %d", n, l)
+		}
+
+		q.Next()
+		q.Get()
+		q.Next()
+		q.Reset()
+
+		if c := q.Peek(); c != 'C' {
+			t.Errorf("test 3 (%s): expecting to read %q, got %q", n, 'C', c)
+		} else if l := q.Len(); l != 0 {
+			t.Errorf("test 4 (%s): expecting to have read 0 bytes, read %d", n, l)
+		} else if got := p.Get(); got != "AB" {
+			t.Errorf("test 5 (%s): expecting parent to read %q, got %q", n, "AB", got)
+		}
+	}
+}
+
+func TestTokeniserOffset(t *testing.T) {
+	for n, p := range tokenisers("ABCDEFGHIJKLMNOPQRSTUVWXYZ") {
+		p.ExceptRun("E")
+
+		if offset := p.Offset(); offset != 0 {
+			t.Errorf("test 1 (%s): expecting offset 0, got %d", n, offset)
+		}
+
+		p.Get()
+		p.ExceptRun("G")
+
+		q := p.SubTokeniser()
+
+		if offset := q.Offset(); offset != 6 {
+			t.Errorf("test 2 (%s): expecting offset 6, got %d", n, offset)
+		}
+
+		q.Next()
+		q.Get()
+
+		if offset := q.Offset(); offset != 7 {
+			t.Errorf("test 3 (%s): expecting offset 7, got %d", n, offset)
+		} else if p.Get(); p.Offset() != 7 {
+			t.Errorf("test 4 (%s): expecting offset 7, got %d", n, p.Offset())
+		}
+	}
+}
+
+func emitTokeniser(t *Tokeniser) (Token, TokenFunc) {
+	switch {
+	case t.Peek() == -1:
+		return t.Done()
+	case t.Accept(" "):
+		t.AcceptRun(" ")
+		t.Get()
+
+		return t.Continue(emitTokeniser)
+	case t.Accept(">"):
+		for t.Peek() == '>' {
+			t.Emit(1)
+			t.Next()
+		}
+
+		return t.Return(1, emitTokeniser)
+	}
+
+	t.ExceptRun(" >")
+	t.Emit(0)
+	t.EmitToken(Token{Type: 2})
+
+	return t.Continue(emitTokeniser)
+}
+
+func TestTokeniserEmit(t *testing.T) {
+	expected := []Token{
+		{Type: 0, Data: "a"},
+		{Type: 2},
+		{Type: 1, Data: ">"},
+		{Type: 1, Data: ">"},
+		{Type: 1, Data: ">"},
+		{Type: 0, Data: "bc"},
+		{Type: 2},
+		{Type: TokenDone},
+	}
+
+	for n, p := range tokenisers("a  >>> bc") {
+		p.TokeniserState(emitTokeniser)
+
+		var tokens []Token
+
+		for tk := range p.Iter {
+			tokens = append(tokens, tk)
+		}
+
+		if !reflect.DeepEqual(tokens, expected) {
+			t.Errorf("test (%s): expecting tokens %v, got %v", n, expected, tokens)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package parser
+
+// Node is a node in a concrete syntax tree, built by a Parser in tree mode.
+//
+// Span covers the children of the Node, from the start of the first to the end
+// of the last; for the root Node of a Phrase, it is the Span of the Phrase.
+type Node struct {
+	Type     PhraseType
+	Children []Child
+	Span     Span
+}
+
+// Child is an element of a Node, which is either a Token or, when Node is not
+// nil, a sub-Node.
+//
+// Span is the Span of the Token, or of the sub-Node.
+//
+// In lossless mode, Leading and Trailing contain the trivia Tokens that
+// surround a Token child; see Parser.LosslessMode.
+type Child struct {
+	Token    Token
+	Node     *Node
+	Span     Span
+	Leading  []Token
+	Trailing []Token
+}
+
+func (n *Node) add(c Child) {
+	if len(n.Children) == 0 {
+		n.Span = c.Span
+	} else {
+		n.Span.End = c.Span.End
+	}
+
+	n.Children = append(n.Children, c)
+}
+
+// Tokens returns all of the Tokens contained within the tree, in order.
+func (n *Node) Tokens() []Token {
+	return n.appendTokens(make([]Token, 0))
+}
+
+func (n *Node) appendTokens(tokens []Token) []Token {
+	for _, c := range n.Children {
+		if c.Node != nil {
+			tokens = c.Node.appendTokens(tokens)
+		} else {
+			tokens = append(tokens, c.Token)
+		}
+	}
+
+	return tokens
+}
+
+// Visitor is used with Walk to traverse a tree of Nodes.
+type Visitor interface {
+	// Enter is called for each Node before its children are visited. The
+	// returned Visitor is used to visit the children of the Node; if it is
+	// nil, the children are skipped.
+	Enter(*Node) Visitor
+
+	// Token is called for each Token child of a Node.
+	Token(Token)
+
+	// Leave is called on the Visitor returned by Enter after all of the
+	// children of the Node have been visited.
+	Leave(*Node)
+}
+
+// Walk traverses the tree rooted at the given Node in depth-first order.
+func Walk(v Visitor, n *Node) {
+	w := v.Enter(n)
+	if w == nil {
+		return
+	}
+
+	for _, c := range n.Children {
+		if c.Node != nil {
+			Walk(w, c.Node)
+		} else {
+			w.Token(c.Token)
+		}
+	}
+
+	w.Leave(n)
+}
+
+// TreeMode enables or disables the building of concrete syntax trees.
+//
+// When enabled, the Tokens read by a PhraseFunc are collected into a tree of
+// Nodes, which can be opened and closed with OpenNode and CloseNode, and the
+// Phrase generated by Return will contain the root of that tree in its Tree
+// field. The Type of the root Node will be the type of the Phrase.
+//
+// Tokens that are retrieved directly with Get will not be included in the
+// tree.
+func (p *Parser) TreeMode(enabled bool) {
+	p.tree = enabled
+	p.nodes = p.nodes[:0]
+}
+
+// OpenNode starts a new Node of the given type, as a child of the currently
+// open Node, into which all subsequently read Tokens will be placed.
+//
+// Any Tokens read before the call are added to the current Node.
+//
+// Does nothing when not in tree mode.
+func (p *Parser) OpenNode(typ PhraseType) {
+	if !p.tree {
+		return
+	}
+
+	p.flush()
+
+	p.nodes = append(p.nodes, &Node{Type: typ, Span: Span{Start: p.lastEnd, End: p.lastEnd}})
+}
+
+// CloseNode ends the most recently opened Node, adding to it all Tokens read
+// since it, or its last child, was opened.
+//
+// Does nothing when not in tree mode or when there is no open Node.
+func (p *Parser) CloseNode() {
+	if !p.tree || len(p.nodes) < 2 {
+		return
+	}
+
+	p.flush()
+	p.closeNode()
+}
+
+func (p *Parser) closeNode() {
+	n := p.nodes[len(p.nodes)-1]
+	p.nodes = p.nodes[:len(p.nodes)-1]
+	parent := p.nodes[len(p.nodes)-1]
+	parent.add(Child{Node: n, Span: n.Span})
+}
+
+func (p *Parser) flush() {
+	if len(p.nodes) == 0 {
+		p.nodes = append(p.nodes, new(Node))
+	}
+
+	n := p.nodes[len(p.nodes)-1]
+
+	for i, tk := range p.tokens[:p.pos] {
+		n.add(Child{Token: tk, Span: p.spans[i], Leading: p.trivia[i].leading, Trailing: p.trivia[i].trailing})
+	}
+
+	p.Get()
+}
+
+func (p *Parser) buildTree(typ PhraseType) *Node {
+	p.flush()
+
+	for len(p.nodes) > 1 {
+		p.closeNode()
+	}
+
+	root := p.nodes[0]
+	root.Type = typ
+	p.nodes = p.nodes[:0]
+
+	return root
+}
@@ -0,0 +1,185 @@
+package parser
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const (
+	treeWord TokenType = iota
+	treeOpen
+	treeClose
+)
+
+const (
+	treeRoot PhraseType = iota
+	treeList
+)
+
+func treeTokeniser(t *Tokeniser) (Token, TokenFunc) {
+	if t.Accept("(") {
+		return t.Return(treeOpen, treeTokeniser)
+	} else if t.Accept(")") {
+		return t.Return(treeClose, treeTokeniser)
+	} else if t.ExceptRun("()"); t.Len() > 0 {
+		return t.Return(treeWord, treeTokeniser)
+	}
+
+	return t.Done()
+}
+
+func treeParser(p *Parser) (Phrase, PhraseFunc) {
+	if p.Peek().Type == TokenDone {
+		return p.Done()
+	}
+
+	for {
+		switch p.Peek().Type {
+		case treeOpen:
+			p.OpenNode(treeList)
+			p.Next()
+		case treeClose:
+			p.Next()
+			p.CloseNode()
+		case TokenDone:
+			return p.Return(treeRoot, treeParser)
+		default:
+			p.Next()
+		}
+	}
+}
+
+type treePrinter struct {
+	strings.Builder
+}
+
+func (t *treePrinter) Enter(n *Node) Visitor {
+	t.WriteString("[")
+
+	return t
+}
+
+func (t *treePrinter) Token(tk Token) {
+	t.WriteString(tk.Data)
+}
+
+func (t *treePrinter) Leave(n *Node) {
+	t.WriteString("]")
+}
+
+func TestTree(t *testing.T) {
+	p := New(NewStringTokeniser("a(b(c)d)e"))
+
+	p.TokeniserState(treeTokeniser)
+	p.PhraserState(treeParser)
+	p.TreeMode(true)
+
+	ph, err := p.GetPhrase()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	tk := func(typ TokenType, data string, start int) Child {
+		return Child{Token: Token{Type: typ, Data: data}, Span: Span{Start: start, End: start + len(data)}}
+	}
+
+	inner := &Node{
+		Type: treeList,
+		Children: []Child{
+			tk(treeOpen, "(", 3),
+			tk(treeWord, "c", 4),
+			tk(treeClose, ")", 5),
+		},
+		Span: Span{Start: 3, End: 6},
+	}
+	outer := &Node{
+		Type: treeList,
+		Children: []Child{
+			tk(treeOpen, "(", 1),
+			tk(treeWord, "b", 2),
+			{Node: inner, Span: inner.Span},
+			tk(treeWord, "d", 6),
+			tk(treeClose, ")", 7),
+		},
+		Span: Span{Start: 1, End: 8},
+	}
+	expected := &Node{
+		Type: treeRoot,
+		Children: []Child{
+			tk(treeWord, "a", 0),
+			{Node: outer, Span: outer.Span},
+			tk(treeWord, "e", 8),
+		},
+		Span: Span{Start: 0, End: 9},
+	}
+
+	if !reflect.DeepEqual(ph.Tree, expected) {
+		t.Errorf("test 1: did not get expected tree")
+	} else if ph.Span != expected.Span {
+		t.Errorf("test 1: expecting span %v, got %v", expected.Span, ph.Span)
+	} else if expected := expected.Tokens(); !reflect.DeepEqual(ph.Data, expected) {
+		t.Errorf("test 2: expecting tokens %v, got %v", expected, ph.Data)
+	}
+
+	var tp treePrinter
+
+	Walk(&tp, ph.Tree)
+
+	if str := tp.String(); str != "[a[(b[(c)]d)]e]" {
+		t.Errorf("test 3: expecting to walk %q, got %q", "[a[(b[(c)]d)]e]", str)
+	}
+
+	p = New(NewStringTokeniser("a(b)"))
+
+	p.TokeniserState(treeTokeniser)
+	p.PhraserState(treeParser)
+
+	if ph, _ = p.GetPhrase(); ph.Tree != nil {
+		t.Errorf("test 4: expecting no tree when not in tree mode")
+	} else if len(ph.Data) != 4 {
+		t.Errorf("test 5: expecting 4 tokens, got %d", len(ph.Data))
+	}
+}
+
+func TestTreeState(t *testing.T) {
+	p := New(NewStringTokeniser("a(b(c)d)e"))
+
+	p.TokeniserState(treeTokeniser)
+	p.TreeMode(true)
+	p.Next()
+
+	s := p.State()
+
+	p.OpenNode(treeList)
+	p.Next()
+	p.Next()
+	p.OpenNode(treeList)
+	p.Next()
+	p.Next()
+	p.Next()
+	p.CloseNode()
+	p.Next()
+	p.OpenNode(treeList)
+	p.Next()
+
+	if !s.Reset() {
+		t.Fatalf("test 1: expecting successful reset")
+	} else if p.Len() != 1 {
+		t.Errorf("test 2: expecting Len 1, got %d", p.Len())
+	}
+
+	p.PhraserState(treeParser)
+
+	var tp treePrinter
+
+	if ph, err := p.GetPhrase(); err != nil {
+		t.Errorf("test 3: unexpected error: %s", err)
+	} else if Walk(&tp, ph.Tree); tp.String() != "[a[(b[(c)]d)]e]" {
+		t.Errorf("test 3: expecting to walk %q, got %q", "[a[(b[(c)]d)]e]", tp.String())
+	} else if ph.Span != (Span{Start: 0, End: 9}) {
+		t.Errorf("test 4: expecting span {0 9}, got %v", ph.Span)
+	} else if s.Reset() {
+		t.Errorf("test 5: expecting failed reset after Return")
+	}
+}
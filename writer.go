@@ -0,0 +1,161 @@
+package parser
+
+import (
+	"io"
+	"slices"
+)
+
+// TokenWriter writes Tokens to an io.Writer, separating adjacent Tokens
+// according to rules declared for pairs of TokenTypes.
+//
+// When PreserveTrivia is false, Tokens of the declared trivia types are
+// dropped, allowing a TokenWriter to be used as a minifier. When it is true,
+// trivia Tokens are written unchanged and separators are only written between
+// Tokens that were not adjacent in the input, so that the original formatting
+// is reproduced wherever Tokens were left unchanged.
+type TokenWriter struct {
+	// Default is written between two adjacent Tokens for which no separator
+	// has been declared.
+	Default string
+
+	// PreserveTrivia determines whether trivia Tokens are written.
+	PreserveTrivia bool
+
+	w        io.Writer
+	rules    map[[2]TokenType]string
+	trivia   []TokenType
+	prev     TokenType
+	adjacent bool
+	original bool
+	end      int
+	err      error
+}
+
+// NewTokenWriter creates a new TokenWriter that writes to the given Writer.
+func NewTokenWriter(w io.Writer) *TokenWriter {
+	return &TokenWriter{w: w}
+}
+
+// Separator declares the string to be written between a Token of type prev
+// and a directly following Token of type next.
+func (tw *TokenWriter) Separator(prev, next TokenType, sep string) {
+	if tw.rules == nil {
+		tw.rules = make(map[[2]TokenType]string)
+	}
+
+	tw.rules[[2]TokenType{prev, next}] = sep
+}
+
+// Trivia declares the TokenTypes, such as whitespace and comments, that are
+// treated as trivia.
+func (tw *TokenWriter) Trivia(types ...TokenType) {
+	tw.trivia = slices.Clone(types)
+}
+
+func (tw *TokenWriter) separator(prev, next TokenType) string {
+	if sep, ok := tw.rules[[2]TokenType{prev, next}]; ok {
+		return sep
+	}
+
+	return tw.Default
+}
+
+func (tw *TokenWriter) write(s string) {
+	if tw.err == nil && s != "" {
+		_, tw.err = io.WriteString(tw.w, s)
+	}
+}
+
+func (tw *TokenWriter) writeTrivia(tk Token) {
+	if tw.PreserveTrivia && tk.Type >= 0 {
+		tw.write(tk.Data)
+
+		tw.adjacent = false
+	}
+}
+
+// WriteToken writes a single Token, preceded by a separator if necessary.
+//
+// When PreserveTrivia is true, Tokens written with WriteToken are assumed to
+// be unchanged from the input, with any trivia between them, and so no
+// separators are written.
+//
+// TokenDone and TokenError Tokens are ignored.
+//
+// Once an error has occurred, no more data will be written and all subsequent
+// calls will return that error.
+func (tw *TokenWriter) WriteToken(tk Token) error {
+	tw.original = false
+
+	return tw.writeToken(tk, tw.PreserveTrivia)
+}
+
+// writeToken writes a Token, with a separator unless the previous Token was
+// adjacent to it in the input.
+func (tw *TokenWriter) writeToken(tk Token, joined bool) error {
+	if tk.Type < 0 {
+		return tw.err
+	}
+
+	if slices.Contains(tw.trivia, tk.Type) {
+		tw.writeTrivia(tk)
+
+		return tw.err
+	}
+
+	if tw.adjacent && !joined {
+		tw.write(tw.separator(tw.prev, tk.Type))
+	}
+
+	tw.write(tk.Data)
+
+	tw.prev = tk.Type
+	tw.adjacent = true
+
+	return tw.err
+}
+
+// WriteTokens writes each of the given Tokens, in order.
+func (tw *TokenWriter) WriteTokens(tokens []Token) error {
+	for _, tk := range tokens {
+		tw.WriteToken(tk)
+	}
+
+	return tw.err
+}
+
+// WriteNode writes all of the Tokens contained within the tree rooted at the
+// given Node.
+//
+// When PreserveTrivia is true, the Leading and Trailing trivia of each Token
+// child, as attached in lossless mode, is also written, and the Span of each
+// Token child is used to determine whether it was adjacent to the previous
+// Token in the input. Tokens whose Spans do not match their Data, such as
+// those in Nodes created to replace parts of the tree, are never considered
+// adjacent.
+func (tw *TokenWriter) WriteNode(n *Node) error {
+	for _, c := range n.Children {
+		if c.Node != nil {
+			tw.WriteNode(c.Node)
+
+			continue
+		}
+
+		for _, tk := range c.Leading {
+			tw.writeTrivia(tk)
+		}
+
+		original := c.Token.Data != "" && c.Span.End-c.Span.Start == len(c.Token.Data)
+
+		tw.writeToken(c.Token, tw.PreserveTrivia && original && tw.original && tw.end == c.Span.Start)
+
+		tw.original = original
+		tw.end = c.Span.End
+
+		for _, tk := range c.Trailing {
+			tw.writeTrivia(tk)
+		}
+	}
+
+	return tw.err
+}
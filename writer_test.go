@@ -0,0 +1,159 @@
+package parser
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func newCSTWriter(sb *strings.Builder, preserve bool) *TokenWriter {
+	tw := NewTokenWriter(sb)
+
+	tw.PreserveTrivia = preserve
+
+	tw.Trivia(fixtureWhitespace, fixtureComment)
+	tw.Separator(fixtureWord, fixtureWord, " ")
+	tw.Separator(fixtureWord, fixtureOpen, " ")
+	tw.Separator(fixtureClose, fixtureWord, " ")
+	tw.Separator(fixtureClose, fixtureOpen, " ")
+
+	return tw
+}
+
+func TestTokenWriter(t *testing.T) {
+	for n, test := range [...]struct {
+		Input    string
+		Preserve bool
+		Output   string
+	}{
+		{
+			Input:  "a  b",
+			Output: "a b",
+		},
+		{
+			Input:  "a ( b c ) ( d ) e",
+			Output: "a (b c) (d) e",
+		},
+		{
+			Input:  "a # comment\n(\n  b\n)\n",
+			Output: "a (b)",
+		},
+		{
+			Input:    "a # comment\n(\n  b\n)\n",
+			Preserve: true,
+			Output:   "a # comment\n(\n  b\n)\n",
+		},
+		{
+			Input:  "a(b c)",
+			Output: "a (b c)",
+		},
+		{
+			Input:    "a(b c)",
+			Preserve: true,
+			Output:   "a(b c)",
+		},
+	} {
+		var sb strings.Builder
+
+		s := NewStringTokeniser(test.Input)
+
+		s.TokeniserState(fixtureTokeniser)
+
+		tw := newCSTWriter(&sb, test.Preserve)
+
+		for tk := range s.Iter {
+			if err := tw.WriteToken(tk); err != nil {
+				t.Errorf("test %d: unexpected error: %s", n+1, err)
+			}
+		}
+
+		if output := sb.String(); output != test.Output {
+			t.Errorf("test %d: expecting output %q, got %q", n+1, test.Output, output)
+		}
+	}
+}
+
+func TestTokenWriterNode(t *testing.T) {
+	for n, test := range [...]struct {
+		Input     string
+		Preserve  bool
+		Unchanged bool
+		Output    string
+	}{
+		{
+			Input:  "a  (b   c)\n\td # comment\n",
+			Output: "a (e f) d",
+		},
+		{
+			Input:    "a  (b   c)\n\td # comment\n",
+			Preserve: true,
+			Output:   "a  (e f)\n\td # comment\n",
+		},
+		{
+			Input:    "# start\n(\n  b\n  c\n)",
+			Preserve: true,
+			Output:   "# start\n(e f)",
+		},
+		{
+			Input:     "a(b c)d",
+			Preserve:  true,
+			Unchanged: true,
+			Output:    "a(b c)d",
+		},
+		{
+			Input:    "a(b c)d",
+			Preserve: true,
+			Output:   "a (e f) d",
+		},
+		{
+			Input:     "a(b c)d",
+			Unchanged: true,
+			Output:    "a (b c) d",
+		},
+	} {
+		var sb strings.Builder
+
+		p := newCSTParser(test.Input)
+
+		ph, err := p.GetPhrase()
+		if err != nil {
+			t.Errorf("test %d: unexpected error: %s", n+1, err)
+
+			continue
+		}
+
+		if !test.Unchanged {
+			ph.Tree.Replace(firstList(ph.Tree), &Node{
+				Type: treeList,
+				Children: []Child{
+					{Token: Token{Type: fixtureOpen, Data: "("}},
+					{Token: Token{Type: fixtureWord, Data: "e"}},
+					{Token: Token{Type: fixtureWord, Data: "f"}},
+					{Token: Token{Type: fixtureClose, Data: ")"}},
+				},
+			})
+		}
+
+		if err := newCSTWriter(&sb, test.Preserve).WriteNode(ph.Tree); err != nil {
+			t.Errorf("test %d: unexpected error: %s", n+1, err)
+		} else if output := sb.String(); output != test.Output {
+			t.Errorf("test %d: expecting output %q, got %q", n+1, test.Output, output)
+		}
+	}
+}
+
+type errorWriter struct{}
+
+var errWriter = errors.New("write error")
+
+func (errorWriter) Write([]byte) (int, error) {
+	return 0, errWriter
+}
+
+func TestTokenWriterError(t *testing.T) {
+	tw := NewTokenWriter(errorWriter{})
+
+	if err := tw.WriteTokens([]Token{{Data: "a"}, {Data: "b"}}); !errors.Is(err, errWriter) {
+		t.Errorf("expecting error %v, got %v", errWriter, err)
+	}
+}